@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// BacktestParams describes the only strategy supported so far: buy with
+// Modal (paying Pokok) after Down consecutive 🔻 ticks, then sell once the
+// calcProfit value reaches TargetProfit.
+type BacktestParams struct {
+	Down         int        `json:"down"`
+	TargetProfit int        `json:"target_profit"`
+	Modal        int        `json:"modal"`
+	Pokok        int        `json:"pokok"`
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+}
+
+type BacktestTrade struct {
+	EntryTime time.Time  `json:"entry_time"`
+	EntryBuy  int        `json:"entry_buy"`
+	ExitTime  *time.Time `json:"exit_time,omitempty"`
+	ExitSell  int        `json:"exit_sell"`
+	Gram      float64    `json:"gram"`
+	Profit    int        `json:"profit"`
+	Open      bool       `json:"open,omitempty"`
+}
+
+type BacktestReport struct {
+	Params         BacktestParams  `json:"params"`
+	Ticks          int             `json:"ticks"`
+	DataFrom       *time.Time      `json:"data_from,omitempty"`
+	DataTo         *time.Time      `json:"data_to,omitempty"`
+	Trades         []BacktestTrade `json:"trades"`
+	Closed         int             `json:"closed"`
+	RealizedPL     int             `json:"realized_pl"`
+	UnrealizedPL   int             `json:"unrealized_pl"`
+	MaxDrawdown    int             `json:"max_drawdown"`
+	MaxDrawdownPct float64         `json:"max_drawdown_pct"`
+}
+
+func defaultBacktestParams() BacktestParams {
+	return BacktestParams{Down: 3, TargetProfit: 500000, Modal: 20000000, Pokok: 19314000}
+}
+
+func (p BacktestParams) validate() error {
+	if p.Down < 1 {
+		return errors.New("down harus minimal 1")
+	}
+	if p.TargetProfit <= 0 || p.Modal <= 0 || p.Pokok <= 0 {
+		return errors.New("target_profit, modal dan pokok harus lebih dari 0")
+	}
+	return nil
+}
+
+// RunBacktest walks ts in order holding at most one position. Drawdown is
+// measured on realized plus mark-to-market equity at every tick.
+func RunBacktest(ts []Tick, p BacktestParams) BacktestReport {
+	r := BacktestReport{Params: p, Trades: []BacktestTrade{}}
+	var open *BacktestTrade
+	var last Tick
+	downs, peak := 0, 0
+	for _, t := range ts {
+		if (p.From != nil && t.UpdatedAt.Before(*p.From)) || (p.To != nil && !t.UpdatedAt.Before(*p.To)) {
+			continue
+		}
+		r.Ticks++
+		if r.DataFrom == nil {
+			at := t.UpdatedAt
+			r.DataFrom = &at
+		}
+		last = t
+		unrealized := 0
+		if open != nil {
+			val := profitValue(open.EntryBuy, t.SellingRate, p.Modal, p.Pokok)
+			if val >= p.TargetProfit {
+				at := t.UpdatedAt
+				open.ExitTime, open.ExitSell, open.Profit, open.Open = &at, t.SellingRate, val, false
+				r.Trades = append(r.Trades, *open)
+				r.Closed++
+				r.RealizedPL += val
+				open, downs = nil, 0
+			} else {
+				unrealized = val
+			}
+		} else if tickStatus(t) == "🔻" {
+			downs++
+			if downs >= p.Down {
+				open = &BacktestTrade{EntryTime: t.UpdatedAt, EntryBuy: t.BuyingRate, Gram: float64(p.Modal) / float64(t.BuyingRate), Open: true}
+				unrealized = profitValue(t.BuyingRate, t.SellingRate, p.Modal, p.Pokok)
+			}
+		} else {
+			downs = 0
+		}
+		equity := r.RealizedPL + unrealized
+		if equity > peak {
+			peak = equity
+		}
+		if dd := peak - equity; dd > r.MaxDrawdown {
+			r.MaxDrawdown = dd
+		}
+	}
+	if r.Ticks > 0 {
+		at := last.UpdatedAt
+		r.DataTo = &at
+	}
+	if open != nil {
+		open.ExitSell = last.SellingRate
+		open.Profit = profitValue(open.EntryBuy, last.SellingRate, p.Modal, p.Pokok)
+		r.UnrealizedPL = open.Profit
+		r.Trades = append(r.Trades, *open)
+	}
+	r.MaxDrawdownPct = round2(float64(r.MaxDrawdown) / float64(p.Pokok) * 100)
+	return r
+}
+
+func BacktestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		jsonError(w, http.StatusMethodNotAllowed, "gunakan POST")
+		return
+	}
+	p := defaultBacktestParams()
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		jsonError(w, http.StatusBadRequest, "body JSON tidak valid: "+err.Error())
+		return
+	}
+	if err := p.validate(); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stateMutex.RLock()
+	ts := append([]Tick(nil), ticks...)
+	stateMutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RunBacktest(ts, p))
+}
+
+// runBacktestCLI implements `goldmonitor backtest`, reading ticks straight
+// from the state file so it can run next to a live server.
+func runBacktestCLI(args []string) int {
+	p := defaultBacktestParams()
+	fs := newFlagSet("backtest")
+	fs.StringVar(&stateFileFlag, "state", "", "file state (bawaan STATE_FILE atau data/state.json)")
+	fs.IntVar(&p.Down, "down", p.Down, "jumlah tick 🔻 berturut-turut sebelum beli")
+	fs.IntVar(&p.TargetProfit, "target", p.TargetProfit, "profit rupiah yang memicu jual")
+	fs.IntVar(&p.Modal, "modal", p.Modal, "rupiah yang dipakai membeli")
+	fs.IntVar(&p.Pokok, "pokok", p.Pokok, "rupiah yang benar-benar dibayar untuk modal")
+	from := fs.String("from", "", "hari pertama yang disertakan (YYYY-MM-DD, WIB)")
+	to := fs.String("to", "", "hari terakhir yang disertakan (YYYY-MM-DD, WIB)")
+	asJSON := fs.Bool("json", false, "cetak laporan sebagai JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var err error
+	if p.From, err = parseDayFlag(*from, 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if p.To, err = parseDayFlag(*to, 1); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := p.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	st, err := readStateFile(stateFilePath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "baca state gagal:", err)
+		return 1
+	}
+	rep := RunBacktest(st.Ticks, p)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+		return 0
+	}
+	if rep.DataFrom != nil {
+		fmt.Printf("Data: %s s/d %s\n", rep.DataFrom.In(wib()).Format("2006-01-02 15:04"), rep.DataTo.In(wib()).Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("Ticks: %d | Beli setelah %d🔻 | Target +Rp%s | Modal Rp%s / Pokok Rp%s\n",
+		rep.Ticks, p.Down, formatRupiah(p.TargetProfit), formatRupiah(p.Modal), formatRupiah(p.Pokok))
+	for i, t := range rep.Trades {
+		exit := "terbuka"
+		if t.ExitTime != nil {
+			exit = t.ExitTime.In(wib()).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%3d. %s beli Rp%s → %s jual Rp%s  %sgr  %s\n", i+1,
+			t.EntryTime.In(wib()).Format("2006-01-02 15:04:05"), formatRupiah(t.EntryBuy),
+			exit, formatRupiah(t.ExitSell), formatGram(t.Gram), signedRupiah(t.Profit))
+	}
+	fmt.Printf("Transaksi selesai: %d | Realisasi %s | Belum terealisasi %s | Max drawdown Rp%s (%.2f%%)\n",
+		rep.Closed, signedRupiah(rep.RealizedPL), signedRupiah(rep.UnrealizedPL), formatRupiah(rep.MaxDrawdown), rep.MaxDrawdownPct)
+	return 0
+}
+
+// parseDayFlag turns a YYYY-MM-DD flag into midnight WIB plus addDays;
+// empty means unbounded.
+func parseDayFlag(s string, addDays int) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, wib())
+	if err != nil {
+		return nil, fmt.Errorf("tanggal tidak valid: %q", s)
+	}
+	t = t.AddDate(0, 0, addDays)
+	return &t, nil
+}
+
+func signedRupiah(v int) string {
+	if v < 0 {
+		return "-Rp" + formatRupiah(-v)
+	}
+	return "+Rp" + formatRupiah(v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunBacktest(t *testing.T) {
+	base := time.Date(2026, 10, 19, 9, 0, 0, 0, wib())
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+	ptr := func(min int) *time.Time { v := at(min); return &v }
+	// Modal equals Pokok, so an entry at 1.000.000 holds exactly one gram.
+	rates := [][3]int{
+		{1010000, 1000000, 0},
+		{1005000, 995000, -5000},
+		{1000000, 990000, -5000},
+		{995000, 970000, -5000},
+		{1070000, 1060000, 75000},
+		{1065000, 1055000, -5000},
+		{1060000, 1050000, -5000},
+	}
+	var ts []Tick
+	for i, r := range rates {
+		ts = append(ts, Tick{BuyingRate: r[0], SellingRate: r[1], Diff: r[2], UpdatedAt: at(i)})
+	}
+	tests := []struct {
+		name       string
+		down       int
+		from, to   *time.Time
+		ticks      int
+		entries    []int
+		closed     int
+		realized   int
+		unrealized int
+		drawdown   int
+	}{
+		// Enters after the 2nd 🔻, sinks to -30.000, exits at +60.000 and
+		// re-enters at the end of the second run of 🔻.
+		{"entry after two downs", 2, nil, nil, 7, []int{2, 6}, 1, 60000, -9434, 30000},
+		{"entry after three downs", 3, nil, nil, 7, []int{3}, 1, 65326, 0, 25126},
+		{"from skips the first trade", 2, ptr(4), nil, 3, []int{6}, 0, 0, -9434, 9434},
+		{"to is exclusive", 2, nil, ptr(4), 4, []int{2}, 0, 0, -30000, 30000},
+		{"empty window", 2, ptr(10), nil, 0, nil, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RunBacktest(ts, BacktestParams{Down: tt.down, TargetProfit: 50000, Modal: 1000000, Pokok: 1000000, From: tt.from, To: tt.to})
+			if r.Ticks != tt.ticks || r.Closed != tt.closed || r.RealizedPL != tt.realized || r.UnrealizedPL != tt.unrealized || r.MaxDrawdown != tt.drawdown {
+				t.Errorf("ticks=%d closed=%d realized=%d unrealized=%d drawdown=%d, want %d %d %d %d %d",
+					r.Ticks, r.Closed, r.RealizedPL, r.UnrealizedPL, r.MaxDrawdown, tt.ticks, tt.closed, tt.realized, tt.unrealized, tt.drawdown)
+			}
+			if len(r.Trades) != len(tt.entries) {
+				t.Fatalf("got %d trades, want %d", len(r.Trades), len(tt.entries))
+			}
+			for i, tr := range r.Trades {
+				if !tr.EntryTime.Equal(at(tt.entries[i])) {
+					t.Errorf("trade %d entered at %s, want minute %d", i, tr.EntryTime, tt.entries[i])
+				}
+				if tr.Open != (tr.ExitTime == nil) {
+					t.Errorf("trade %d open=%v with exit %v", i, tr.Open, tr.ExitTime)
+				}
+			}
+			if want := round2(float64(tt.drawdown) / 10000); r.MaxDrawdownPct != want {
+				t.Errorf("drawdown pct = %v, want %v", r.MaxDrawdownPct, want)
+			}
+		})
+	}
+}
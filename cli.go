@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const cliUsage = `Penggunaan: goldmonitor <perintah> [flag]
+
+Perintah:
+  serve      Jalankan fetcher, dashboard web dan bot Telegram (bawaan)
+  web-only   Jalankan dashboard web dari file state yang ditulis bot-only
+  bot-only   Jalankan fetcher dan bot Telegram tanpa server HTTP
+  replay     Putar ulang rekaman RECORD_DIR ke dashboard
+  export     Tulis tick tersimpan sebagai CSV atau JSON
+  import     Gabungkan tick dari CSV ke file state
+  backfill   Isi file state dari rekaman respons upstream
+  backtest   Uji strategi beli/jual pada tick tersimpan
+
+Jalankan "goldmonitor <perintah> -h" untuk daftar flag.
+`
+
+// runCLI dispatches os.Args[1:]; no command (or only flags) means serve so
+// existing deployments keep working.
+func runCLI(args []string) int {
+	InitLogger()
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		fmt.Print(cliUsage)
+		return 0
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe("serve", args, serveOptions{web: true, bot: true, persist: true, alerts: true})
+	}
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "serve":
+		return runServe(cmd, rest, serveOptions{web: true, bot: true, persist: true, alerts: true})
+	case "web-only":
+		return runServe(cmd, rest, serveOptions{web: true, follow: true})
+	case "bot-only":
+		return runServe(cmd, rest, serveOptions{bot: true, persist: true, alerts: true})
+	case "replay":
+		return runReplay(rest)
+	case "export":
+		return runExport(rest)
+	case "import":
+		return runImport(rest)
+	case "backfill":
+		return runBackfill(rest)
+	case "backtest":
+		return runBacktestCLI(rest)
+	}
+	fmt.Fprintf(os.Stderr, "perintah tidak dikenal: %s\n\n%s", cmd, cliUsage)
+	return 2
+}
+
+// newFlagSet is a flag set whose usage header matches cliUsage.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Penggunaan: goldmonitor %s [flag]\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func defaultPort() string {
+	if p := os.Getenv("PORT"); p != "" {
+		return p
+	}
+	return "8000"
+}
+
+func runServe(name string, args []string, o serveOptions) int {
+	fs := newFlagSet(name)
+	fs.StringVar(&stateFileFlag, "state", "", "file state (bawaan STATE_FILE atau data/state.json)")
+	source := new(string)
+	if !o.follow {
+		source = fs.String("source", os.Getenv("PRICE_SOURCE"), "sumber harga: live, sim atau replay")
+	}
+	if o.web {
+		fs.StringVar(&o.port, "port", defaultPort(), "port HTTP")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if o.follow {
+		return serve(o)
+	}
+	if err := InitPriceSource(*source); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return serve(o)
+}
+
+func runReplay(args []string) int {
+	fs := newFlagSet("replay")
+	path := fs.String("path", os.Getenv("REPLAY_PATH"), "file atau direktori rekaman")
+	speed := fs.Float64("speed", envFloat("REPLAY_SPEED", 1), "kecepatan putar; 0 memutar secepat polling")
+	port := fs.String("port", defaultPort(), "port HTTP")
+	bot := fs.Bool("bot", false, "jalankan juga bot Telegram; alert posisi dikirim ke SIM_ALERT_CHAT_ID atau ADMIN_CHAT_ID")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	src, err := newReplaySource(*path, *speed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	priceSource = src
+	return serve(serveOptions{web: true, bot: *bot, alerts: true, port: *port})
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var csvHeader = []string{"updated_at", "buying_rate", "selling_rate", "buying_rate_exact", "selling_rate_exact", "diff", "xau_usd"}
+
+// mergeTicks combines two tick sets ordered by updated_at. On duplicate
+// timestamps the tick from a wins and diffs are recomputed. Nothing is
+// dropped; see retainTicks.
+func mergeTicks(a, b []Tick) []Tick {
+	all := append(append([]Tick(nil), a...), b...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].UpdatedAt.Before(all[j].UpdatedAt) })
+	out := all[:0]
+	for _, t := range all {
+		if n := len(out); n > 0 && out[n-1].UpdatedAt.Equal(t.UpdatedAt) {
+			continue
+		}
+		t.Diff = 0
+		if n := len(out); n > 0 {
+			t.Diff = t.BuyingRate - out[n-1].BuyingRate
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// retainTicks applies the server's TICK_RETENTION to ts and warns on stderr
+// about anything dropped, since the server would discard it at load anyway.
+// It reports whether all ticks were kept.
+func retainTicks(ts []Tick) ([]Tick, bool) {
+	kept := pruneTicks(ts, time.Now())
+	if dropped := len(ts) - len(kept); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "peringatan: %d tick lebih lama dari TICK_RETENTION=%s dibuang (tertua %s); naikkan TICK_RETENTION untuk menyimpannya\n",
+			dropped, tickRetention(), ts[0].UpdatedAt.In(wib()).Format("2006-01-02 15:04"))
+		return kept, false
+	}
+	return kept, true
+}
+
+// mergeFx combines quotes for one pair ordered by time, dropping repeats
+// of the previous rate as appendFx does and recomputing the changes.
+func mergeFx(a, b []FxQuote) []FxQuote {
+	all := append(append([]FxQuote(nil), a...), b...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].At.Before(all[j].At) })
+	var out []FxQuote
+	for _, x := range all {
+		x.Change, x.ChangePct = 0, 0
+		if n := len(out); n > 0 {
+			prev := out[n-1].Rate
+			if prev == x.Rate {
+				continue
+			}
+			x.Change = x.Rate - prev
+			if prev != 0 {
+				x.ChangePct = x.Change / prev * 100
+			}
+		}
+		out = append(out, x)
+	}
+	if max := fxLogMax(); len(out) > max {
+		out = out[len(out)-max:]
+	}
+	return out
+}
+
+func runExport(args []string) int {
+	fs := newFlagSet("export")
+	fs.StringVar(&stateFileFlag, "state", "", "file state (bawaan STATE_FILE atau data/state.json)")
+	format := fs.String("format", "csv", "format keluaran: csv atau json")
+	from := fs.String("from", "", "hari pertama yang disertakan (YYYY-MM-DD, WIB)")
+	to := fs.String("to", "", "hari terakhir yang disertakan (YYYY-MM-DD, WIB)")
+	out := fs.String("o", "-", "file keluaran, - untuk stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	fromT, err := parseDayFlag(*from, 0)
+	if err == nil {
+		var toT *time.Time
+		if toT, err = parseDayFlag(*to, 1); err == nil {
+			err = exportTicks(*format, *out, fromT, toT)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func exportTicks(format, out string, from, to *time.Time) error {
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("format tidak dikenal: %s", format)
+	}
+	p, err := readStateFile(stateFilePath())
+	if err != nil {
+		return err
+	}
+	var ts []Tick
+	for _, t := range p.Ticks {
+		if (from == nil || !t.UpdatedAt.Before(*from)) && (to == nil || t.UpdatedAt.Before(*to)) {
+			ts = append(ts, t)
+		}
+	}
+	w := io.Writer(os.Stdout)
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ts)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, t := range ts {
+		cw.Write([]string{
+			t.UpdatedAt.In(wib()).Format(time.RFC3339),
+			strconv.Itoa(t.BuyingRate),
+			strconv.Itoa(t.SellingRate),
+			t.BuyingRateExact,
+			t.SellingRateExact,
+			strconv.Itoa(t.Diff),
+			strconv.FormatFloat(t.XauUsd, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readTicksCSV accepts the export format; only updated_at, buying_rate and
+// selling_rate are required and columns may be in any order. updated_at is
+// RFC 3339 or Treasury's "2006-01-02 15:04:05" in WIB.
+func readTicksCSV(r io.Reader) ([]Tick, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("baca header CSV: %w", err)
+	}
+	col := make(map[string]int)
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, need := range csvHeader[:3] {
+		if _, ok := col[need]; !ok {
+			return nil, fmt.Errorf("kolom %q tidak ada", need)
+		}
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var out []Tick
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		raw := get(rec, "updated_at")
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			at, err = time.ParseInLocation(treasuryTimeLayout, raw, wib())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("baris %d: updated_at tidak valid: %q", line, raw)
+		}
+		buy, err1 := strconv.Atoi(get(rec, "buying_rate"))
+		sell, err2 := strconv.Atoi(get(rec, "selling_rate"))
+		if err1 != nil || err2 != nil || buy <= 0 || sell <= 0 || sell > buy {
+			return nil, fmt.Errorf("baris %d: harga tidak valid", line)
+		}
+		t := Tick{
+			BuyingRate:       buy,
+			SellingRate:      sell,
+			BuyingRateExact:  get(rec, "buying_rate_exact"),
+			SellingRateExact: get(rec, "selling_rate_exact"),
+			UpdatedAt:        at.In(wib()),
+		}
+		if t.BuyingRateExact == "" {
+			t.BuyingRateExact = strconv.Itoa(buy)
+		}
+		if t.SellingRateExact == "" {
+			t.SellingRateExact = strconv.Itoa(sell)
+		}
+		t.XauUsd, _ = strconv.ParseFloat(get(rec, "xau_usd"), 64)
+		out = append(out, t)
+	}
+}
+
+// loadStateForUpdate reads the state file for import and backfill; a
+// missing file starts empty.
+func loadStateForUpdate() (persistedState, error) {
+	p, err := readStateFile(stateFilePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return persistedState{FxHistory: make(map[string][]FxQuote)}, nil
+	}
+	return p, err
+}
+
+func runImport(args []string) int {
+	fs := newFlagSet("import")
+	fs.StringVar(&stateFileFlag, "state", "", "file state (bawaan STATE_FILE atau data/state.json)")
+	replace := fs.Bool("replace", false, "ganti tick tersimpan alih-alih menggabungkan")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Penggunaan: goldmonitor import [flag] <file.csv|->\nHentikan server dulu; server menimpa file state saat berhenti.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	r := io.Reader(os.Stdin)
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	imported, err := readTicksCSV(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p, err := loadStateForUpdate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *replace {
+		p.Ticks = nil
+	}
+	before := len(p.Ticks)
+	var complete bool
+	p.Ticks, complete = retainTicks(mergeTicks(p.Ticks, imported))
+	if err := writeStateFile(stateFilePath(), p); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d baris dibaca, %d tick tersimpan (sebelumnya %d) di %s\n", len(imported), len(p.Ticks), before, stateFilePath())
+	if !complete {
+		return 1
+	}
+	return 0
+}
+
+// runBackfill rebuilds ticks and FX quotes from recordings made with
+// RECORD_DIR, e.g. after the state file was lost or the server was down
+// while another instance kept recording.
+func runBackfill(args []string) int {
+	fs := newFlagSet("backfill")
+	fs.StringVar(&stateFileFlag, "state", "", "file state (bawaan STATE_FILE atau data/state.json)")
+	dir := fs.String("recordings", os.Getenv("RECORD_DIR"), "file atau direktori rekaman")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "-recordings atau RECORD_DIR wajib diisi")
+		return 2
+	}
+	recs, err := ReadRecordings(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var ts []Tick
+	fx := make(map[string][]FxQuote)
+	skipped := 0
+	// Only the configured IDR pairs belong in FxHistory; the XAU reference
+	// spot is recorded too but is a live-only input, not history.
+	fxPairs := make(map[string]bool)
+	for _, pair := range FxPairs() {
+		fxPairs[pair] = true
+	}
+	for _, r := range recs {
+		if r.Code != 200 {
+			skipped++
+			continue
+		}
+		switch r.Kind {
+		case recordTreasury:
+			rate, _, err := decodeTreasury([]byte(r.Body))
+			if err != nil {
+				skipped++
+				continue
+			}
+			at, _ := time.ParseInLocation(treasuryTimeLayout, rate.UpdatedAt, wib())
+			ts = append(ts, Tick{
+				BuyingRate:       rate.BuyingRate.Rupiah(),
+				SellingRate:      rate.SellingRate.Rupiah(),
+				BuyingRateExact:  rate.BuyingRate.String(),
+				SellingRateExact: rate.SellingRate.String(),
+				UpdatedAt:        at,
+			})
+		case recordQuote:
+			if !fxPairs[r.Symbol] {
+				skipped++
+				continue
+			}
+			doc, err := goquery.NewDocumentFromReader(bytes.NewReader([]byte(r.Body)))
+			if err != nil {
+				skipped++
+				continue
+			}
+			_, rate, _, ok := ExtractQuote(doc, r.Symbol)
+			if !ok {
+				skipped++
+				continue
+			}
+			fx[r.Symbol] = append(fx[r.Symbol], FxQuote{Rate: rate, At: r.At.In(wib())})
+		}
+	}
+	p, err := loadStateForUpdate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var complete bool
+	p.Ticks, complete = retainTicks(mergeTicks(p.Ticks, ts))
+	for pair, items := range fx {
+		p.FxHistory[pair] = mergeFx(p.FxHistory[pair], items)
+	}
+	if err := writeStateFile(stateFilePath(), p); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d rekaman: %d tick, %d pair kurs, %d dilewati; %d tick tersimpan di %s\n", len(recs), len(ts), len(fx), skipped, len(p.Ticks), stateFilePath())
+	if !complete {
+		return 1
+	}
+	return 0
+}
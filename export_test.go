@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeTicks(t *testing.T) {
+	base := time.Date(2026, 10, 19, 9, 0, 0, 0, wib())
+	tick := func(min, buy, diff int) Tick {
+		return Tick{BuyingRate: buy, SellingRate: buy - 100, Diff: diff, UpdatedAt: base.Add(time.Duration(min) * time.Minute)}
+	}
+	tests := []struct {
+		name string
+		a, b []Tick
+		want []Tick
+	}{
+		{"both empty", nil, nil, []Tick{}},
+		{"diff recomputed", []Tick{tick(0, 1000, 99)}, nil, []Tick{tick(0, 1000, 0)}},
+		// a wins on equal timestamps; order of the inputs does not matter.
+		{"interleaved with duplicate",
+			[]Tick{tick(2, 1020, 0), tick(0, 1000, 0)},
+			[]Tick{tick(3, 1000, 0), tick(1, 1010, 0), tick(2, 9999, 0)},
+			[]Tick{tick(0, 1000, 0), tick(1, 1010, 10), tick(2, 1020, 10), tick(3, 1000, -20)}},
+		{"same instant in another zone", []Tick{tick(0, 1000, 0)}, []Tick{{BuyingRate: 5, UpdatedAt: base.UTC()}}, []Tick{tick(0, 1000, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeTicks(tt.a, tt.b)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d ticks, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if !g.UpdatedAt.Equal(w.UpdatedAt) || g.BuyingRate != w.BuyingRate || g.SellingRate != w.SellingRate || g.Diff != w.Diff {
+					t.Errorf("tick %d = %d/%d diff %d at %s, want %d/%d diff %d at %s", i,
+						g.BuyingRate, g.SellingRate, g.Diff, g.UpdatedAt, w.BuyingRate, w.SellingRate, w.Diff, w.UpdatedAt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	feedLive  = "live"
+	feedStale = "stale"
+	feedDown  = "down"
+)
+
+type FeedStatus struct {
+	Status      string `json:"status"`
+	LastSuccess string `json:"last_success,omitempty"`
+	LastChange  string `json:"last_change,omitempty"`
+	Since       string `json:"since"`
+}
+
+var lastChangeAt = make(map[string]time.Time)
+
+func markSourceChange(source string) {
+	sourceMutex.Lock()
+	lastChangeAt[source] = time.Now()
+	sourceMutex.Unlock()
+}
+
+func feedSources() []string {
+	out := []string{"treasury"}
+	for _, p := range FxPairs() {
+		out = append(out, "google:"+p)
+	}
+	return out
+}
+
+// classifyFeed marks a source down when fetches keep failing and stale when
+// fetches succeed but the value has not moved for too long. Staleness is
+// only judged while the market is open, or always for a non-live source. Before the first success or change
+// the process start time stands in.
+func classifyFeed(lastSuccess, lastChange, now time.Time) string {
+	if lastSuccess.IsZero() {
+		lastSuccess = startedAt
+	}
+	if lastChange.IsZero() {
+		lastChange = startedAt
+	}
+	if now.Sub(lastSuccess) > envDuration("FEED_DOWN_AFTER", 2*time.Minute) {
+		return feedDown
+	}
+	if (!sourceIsLive() || marketOpen(now)) && now.Sub(lastChange) > feedStaleAfter() {
+		return feedStale
+	}
+	return feedLive
+}
+
+func feedStaleAfter() time.Duration {
+	return envDuration("FEED_STALE_AFTER", 10*time.Minute)
+}
+
+func formatFeedTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(wib()).Format(time.RFC3339)
+}
+
+// CheckFeeds re-evaluates every source. Timestamps are refreshed in place;
+// only status transitions are broadcast and reported to the admin.
+func CheckFeeds(now time.Time) {
+	var alerts []string
+	changed := false
+	for _, src := range feedSources() {
+		sourceMutex.Lock()
+		success, change := lastSuccessAt[src], lastChangeAt[src]
+		sourceMutex.Unlock()
+		status := classifyFeed(success, change, now)
+		stateMutex.Lock()
+		prev, seen := state.FeedStatus[src]
+		next := FeedStatus{Status: status, LastSuccess: formatFeedTime(success), LastChange: formatFeedTime(change), Since: prev.Since}
+		if !seen || prev.Status != status {
+			next.Since = formatFeedTime(now)
+			changed = true
+			if seen || status != feedLive {
+				alerts = append(alerts, feedAlert(src, prev.Status, status, success, change))
+			}
+		}
+		state.FeedStatus[src] = next
+		stateMutex.Unlock()
+	}
+	if changed {
+		BroadcastState()
+	}
+	for _, a := range alerts {
+		NotifyAdmin(a)
+	}
+}
+
+func feedAlert(src, from, to string, success, change time.Time) string {
+	icon := map[string]string{feedLive: "✅", feedStale: "⚠️", feedDown: "🛑"}[to]
+	if from == "" {
+		from = "-"
+	}
+	slog.Warn("feed status changed", "source", src, "from", from, "to", to, "last_success", success, "last_change", change)
+	msg := fmt.Sprintf("%s <b>Feed %s</b>: %s ➺ %s", icon, src, from, to)
+	if !success.IsZero() {
+		msg += "\nSukses terakhir: " + success.In(wib()).Format("15:04:05") + " WIB"
+	}
+	if !change.IsZero() {
+		msg += "\nPerubahan terakhir: " + change.In(wib()).Format("15:04:05") + " WIB"
+	}
+	return msg
+}
+
+func StartFeedMonitor(ctx context.Context) {
+	for sleepCtx(ctx, 5*time.Second) {
+		CheckFeeds(time.Now())
+	}
+}
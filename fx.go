@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FxQuote is one Google Finance rate as stored, without display formatting.
+type FxQuote struct {
+	Rate      float64   `json:"rate"`
+	Change    float64   `json:"change"`
+	ChangePct float64   `json:"change_pct"`
+	At        time.Time `json:"at"`
+}
+
+// FxItem is the dashboard row derived from an FxQuote by presentFx.
+type FxItem struct {
+	Price     string  `json:"price"`
+	Time      string  `json:"time"`
+	Rate      float64 `json:"rate"`
+	Timestamp string  `json:"timestamp"`
+	Change    float64 `json:"change"`
+	ChangePct float64 `json:"change_pct"`
+}
+
+func presentFx(q FxQuote) FxItem {
+	at := q.At.In(wib())
+	return FxItem{
+		Price:     formatQuote(q.Rate),
+		Time:      at.Format("15:04:05"),
+		Rate:      q.Rate,
+		Timestamp: at.Format(time.RFC3339),
+		Change:    q.Change,
+		ChangePct: q.ChangePct,
+	}
+}
+
+const (
+	fxDisplayMax   = 11
+	primaryFxPair  = "USD-IDR"
+	defaultFxPairs = "USD-IDR,SGD-IDR,EUR-IDR,MYR-IDR"
+)
+
+var (
+	fxLog    = make(map[string][]FxQuote)
+	fxHealth = make(map[string]*fxScrapeHealth)
+)
+
+// FxPairs returns the Google Finance pairs to track from FX_PAIRS; USD-IDR
+// always comes first because the dashboard and /harga depend on it.
+func FxPairs() []string {
+	raw := os.Getenv("FX_PAIRS")
+	if raw == "" {
+		raw = defaultFxPairs
+	}
+	pairs := []string{primaryFxPair}
+	seen := map[string]bool{primaryFxPair: true}
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		pairs = append(pairs, p)
+	}
+	return pairs
+}
+
+func fxLogMax() int {
+	if n, err := strconv.Atoi(os.Getenv("FX_HISTORY_MAX")); err == nil && n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(os.Getenv("USDIDR_HISTORY_MAX")); err == nil && n > 0 {
+		return n
+	}
+	return 2880
+}
+
+func FetchFx(ctx context.Context, pair string) error {
+	rate, at, err := fetchQuote(ctx, pair)
+	if err != nil {
+		return err
+	}
+	if appendFx(pair, rate, at) {
+		slog.Debug("new fx quote", "source", "google:"+pair, "rate", rate)
+		BroadcastState()
+	}
+	return nil
+}
+
+// fetchQuote scrapes one Google Finance quote page and updates the scraper
+// health for symbol. The returned time is when the quote was observed: now
+// for live data, the recording time for replays.
+func fetchQuote(ctx context.Context, symbol string) (float64, time.Time, error) {
+	source := "google:" + symbol
+	start := time.Now()
+	resp, err := priceSource.FetchQuote(ctx, symbol)
+	if err != nil {
+		code, reason := failureOf(err)
+		observeFetch(source, start, code, reason, err)
+		return 0, time.Time{}, err
+	}
+	if resp.Code != http.StatusOK {
+		serr := newStatusError(resp)
+		observeFetch(source, start, resp.Code, "status", serr)
+		return 0, time.Time{}, serr
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		observeFetch(source, start, resp.Code, "parse", err)
+		return 0, time.Time{}, err
+	}
+	_, rate, strategy, ok := ExtractQuote(doc, symbol)
+	recordScrape(symbol, strategy, ok)
+	if !ok {
+		err := errors.New("no extraction strategy matched")
+		observeFetch(source, start, resp.Code, "extract", err)
+		return 0, time.Time{}, err
+	}
+	observeFetch(source, start, resp.Code, "", nil)
+	mLastFx.Set(rate, symbol)
+	at := resp.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return rate, at, nil
+}
+
+// appendFx records a new quote for pair unless it repeats the last rate and
+// reports whether the state changed.
+func appendFx(pair string, rate float64, at time.Time) bool {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	h := fxLog[pair]
+	if len(h) > 0 && h[len(h)-1].Rate == rate {
+		return false
+	}
+	item := FxQuote{Rate: rate, At: at.In(wib())}
+	if len(h) > 0 {
+		prev := h[len(h)-1].Rate
+		item.Change = rate - prev
+		if prev != 0 {
+			item.ChangePct = item.Change / prev * 100
+		}
+	}
+	h = append(h, item)
+	if max := fxLogMax(); len(h) > max {
+		h = h[len(h)-max:]
+	}
+	fxLog[pair] = h
+	mFxUpdates.Inc(pair)
+	markSourceChange("google:" + pair)
+	syncFxState(pair)
+	if pair == primaryFxPair {
+		updateXau()
+	}
+	markDirty()
+	return true
+}
+
+// syncFxState must be called with stateMutex held.
+func syncFxState(pair string) {
+	tail := presentFxTail(fxLog[pair], fxDisplayMax)
+	state.FxHistory[pair] = tail
+	if pair == primaryFxPair {
+		state.UsdIdrHistory = tail
+	}
+}
+
+// presentFxTail formats the last n quotes of h; n <= 0 means all.
+func presentFxTail(h []FxQuote, n int) []FxItem {
+	if n > 0 && len(h) > n {
+		h = h[len(h)-n:]
+	}
+	out := make([]FxItem, len(h))
+	for i, q := range h {
+		out[i] = presentFx(q)
+	}
+	return out
+}
+
+// LatestFx returns the most recent quote for pair.
+func LatestFx(pair string) (FxItem, bool) {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	h := fxLog[pair]
+	if len(h) == 0 {
+		return FxItem{}, false
+	}
+	return presentFx(h[len(h)-1]), true
+}
+
+// parseRate reads a Google Finance quote such as "16,234.50" or "16.234,50".
+// When only one kind of separator appears, a trailing group of exactly three
+// digits is taken as thousands.
+func parseRate(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' || r == '-' {
+			return r
+		}
+		return -1
+	}, s)
+	lastDot, lastComma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+		}
+	case lastComma >= 0:
+		if strings.Count(s, ",") > 1 || len(s)-lastComma-1 == 3 {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.Replace(s, ",", ".", 1)
+		}
+	case lastDot >= 0:
+		if strings.Count(s, ".") > 1 || len(s)-lastDot-1 == 3 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+func FxHandler(w http.ResponseWriter, r *http.Request) {
+	pair := strings.ToUpper(r.URL.Query().Get("pair"))
+	if pair == "" {
+		pair = primaryFxPair
+	}
+	n, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	stateMutex.RLock()
+	var b []byte
+	if isRawView(r) {
+		h := fxLog[pair]
+		if n > 0 && n < len(h) {
+			h = h[len(h)-n:]
+		}
+		b, _ = json.Marshal(h)
+	} else {
+		b, _ = json.Marshal(presentFxTail(fxLog[pair], n))
+	}
+	stateMutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func UsdIdrHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	q.Set("pair", primaryFxPair)
+	r.URL.RawQuery = q.Encode()
+	FxHandler(w, r)
+}
@@ -6,9 +6,7 @@ require (
 	github.com/PuerkitoBio/goquery v1.8.1
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/gorilla/websocket v1.5.3
+	golang.org/x/net v0.17.0
 )
 
-require (
-	github.com/andybalholm/cascadia v1.3.1 // indirect
-	golang.org/x/net v0.17.0 // indirect
-)
+require github.com/andybalholm/cascadia v1.3.1 // indirect
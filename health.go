@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type SourceHealth struct {
+	OK          bool    `json:"ok"`
+	LastSuccess string  `json:"last_success,omitempty"`
+	AgeSeconds  float64 `json:"age_seconds"`
+	MaxAge      float64 `json:"max_age_seconds"`
+}
+
+var (
+	sourceMutex   sync.Mutex
+	lastSuccessAt = make(map[string]time.Time)
+	startedAt     = time.Now()
+)
+
+func markSourceSuccess(source string) {
+	sourceMutex.Lock()
+	lastSuccessAt[source] = time.Now()
+	sourceMutex.Unlock()
+}
+
+func lastSourceSuccess(source string) time.Time {
+	sourceMutex.Lock()
+	defer sourceMutex.Unlock()
+	return lastSuccessAt[source]
+}
+
+func readyMaxAge() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("READY_MAX_AGE")); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Minute
+}
+
+func readinessSources() []string {
+	return []string{"treasury", "google:" + primaryFxPair}
+}
+
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"uptime_seconds": time.Since(startedAt).Seconds(),
+	})
+}
+
+// ReadyzHandler fails with 503 once any required upstream has gone longer
+// than READY_MAX_AGE without a successful fetch.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	maxAge := readyMaxAge()
+	ready := true
+	sources := make(map[string]SourceHealth)
+	for _, src := range readinessSources() {
+		last := lastSourceSuccess(src)
+		h := SourceHealth{MaxAge: maxAge.Seconds()}
+		if !last.IsZero() {
+			h.LastSuccess = last.In(wib()).Format(time.RFC3339)
+			h.AgeSeconds = now.Sub(last).Seconds()
+			h.OK = now.Sub(last) <= maxAge
+		} else {
+			h.AgeSeconds = now.Sub(startedAt).Seconds()
+		}
+		if !h.OK {
+			ready = false
+		}
+		sources[src] = h
+	}
+	status := "ok"
+	code := http.StatusOK
+	if !ready {
+		status = "fail"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"sources": sources,
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	sharedTransport = newSharedTransport()
+	treasuryClient  = &http.Client{Transport: sharedTransport, Timeout: envDuration("TREASURY_TIMEOUT", 5*time.Second)}
+	googleClient    = &http.Client{Transport: sharedTransport, Timeout: envDuration("FX_TIMEOUT", 5*time.Second)}
+)
+
+// newSharedTransport keeps TLS connections to Treasury and Google alive
+// between polls. HTTP_PROXY_URL overrides the standard proxy variables.
+// Request deadlines come from each client's Timeout, so the transport sets
+// no response-header limit of its own.
+func newSharedTransport() *http.Transport {
+	proxy := http.ProxyFromEnvironment
+	if raw := os.Getenv("HTTP_PROXY_URL"); raw != "" {
+		if u, err := url.Parse(raw); err == nil {
+			proxy = http.ProxyURL(u)
+		} else {
+			slog.Error("invalid HTTP_PROXY_URL, using environment proxy", "err", err)
+		}
+	}
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          32,
+		MaxIdleConnsPerHost:   8,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+func envInt(key string, def int64) int64 {
+	if n, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// readLimited reads at most limit bytes and fails instead of truncating
+// silently when the body is larger.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return b, nil
+}
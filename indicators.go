@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Candle struct {
+	Start time.Time `json:"start"`
+	Open  int       `json:"open"`
+	High  int       `json:"high"`
+	Low   int       `json:"low"`
+	Close int       `json:"close"`
+	Ticks int       `json:"ticks"`
+}
+
+type IndicatorPoint struct {
+	Time   time.Time `json:"time"`
+	Value  float64   `json:"value"`
+	Upper  float64   `json:"upper,omitempty"`
+	Lower  float64   `json:"lower,omitempty"`
+	Middle float64   `json:"middle,omitempty"`
+}
+
+// IndicatorSnapshot holds the latest value of each indicator on the default
+// interval; nil means there are not enough candles yet.
+type IndicatorSnapshot struct {
+	Interval  string   `json:"interval"`
+	SMA       *float64 `json:"sma,omitempty"`
+	EMA       *float64 `json:"ema,omitempty"`
+	RSI       *float64 `json:"rsi,omitempty"`
+	BollUpper *float64 `json:"boll_upper,omitempty"`
+	BollLower *float64 `json:"boll_lower,omitempty"`
+}
+
+var (
+	defaultPeriods   = map[string]int{"sma": 20, "ema": 20, "rsi": 14, "bollinger": 20}
+	indicatorAliases = map[string]string{"bb": "bollinger"}
+)
+
+// indicatorCandles are the INDICATOR_INTERVAL candles behind
+// state.Indicators, extended tick by tick; indicatorCandleTicks counts the
+// ticks in them. Guarded by stateMutex.
+var (
+	indicatorCandles        []Candle
+	indicatorCandleInterval time.Duration
+	indicatorCandleTicks    int
+)
+
+// indicatorName resolves aliases and reports whether name is known.
+func indicatorName(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if a, ok := indicatorAliases[name]; ok {
+		name = a
+	}
+	_, ok := defaultPeriods[name]
+	return name, ok
+}
+
+func parseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("interval tidak valid: %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("interval tidak valid: %q", s)
+	}
+	return d, nil
+}
+
+func indicatorInterval() string {
+	if v := os.Getenv("INDICATOR_INTERVAL"); v != "" {
+		if _, err := parseInterval(v); err == nil {
+			return v
+		}
+	}
+	return "15m"
+}
+
+// bucketStart aligns t to interval boundaries counted from midnight WIB, so
+// 1d candles open at 00:00 WIB rather than UTC.
+func bucketStart(t time.Time, interval time.Duration) time.Time {
+	const offset = 7 * time.Hour
+	return t.Add(offset).Truncate(interval).Add(-offset).In(wib())
+}
+
+// BuildCandles groups ticks into OHLC candles on the buying rate.
+func BuildCandles(ts []Tick, interval time.Duration) []Candle {
+	var out []Candle
+	for _, t := range ts {
+		out = addToCandles(out, t, interval)
+	}
+	return out
+}
+
+// addToCandles folds one tick, no older than the last candle, into cs.
+func addToCandles(cs []Candle, t Tick, interval time.Duration) []Candle {
+	start := bucketStart(t.UpdatedAt, interval)
+	if n := len(cs); n > 0 && cs[n-1].Start.Equal(start) {
+		c := &cs[n-1]
+		if t.BuyingRate > c.High {
+			c.High = t.BuyingRate
+		}
+		if t.BuyingRate < c.Low {
+			c.Low = t.BuyingRate
+		}
+		c.Close = t.BuyingRate
+		c.Ticks++
+		return cs
+	}
+	return append(cs, Candle{Start: start, Open: t.BuyingRate, High: t.BuyingRate, Low: t.BuyingRate, Close: t.BuyingRate, Ticks: 1})
+}
+
+func closes(cs []Candle) []float64 {
+	out := make([]float64, len(cs))
+	for i, c := range cs {
+		out[i] = float64(c.Close)
+	}
+	return out
+}
+
+func SMA(cs []Candle, period int) []IndicatorPoint {
+	v := closes(cs)
+	var out []IndicatorPoint
+	sum := 0.0
+	for i := range v {
+		sum += v[i]
+		if i >= period {
+			sum -= v[i-period]
+		}
+		if i >= period-1 {
+			out = append(out, IndicatorPoint{Time: cs[i].Start, Value: round2(sum / float64(period))})
+		}
+	}
+	return out
+}
+
+// EMA is seeded with the SMA of the first period candles.
+func EMA(cs []Candle, period int) []IndicatorPoint {
+	v := closes(cs)
+	if len(v) < period {
+		return nil
+	}
+	k := 2 / float64(period+1)
+	ema := 0.0
+	for _, x := range v[:period] {
+		ema += x
+	}
+	ema /= float64(period)
+	out := []IndicatorPoint{{Time: cs[period-1].Start, Value: round2(ema)}}
+	for i := period; i < len(v); i++ {
+		ema = v[i]*k + ema*(1-k)
+		out = append(out, IndicatorPoint{Time: cs[i].Start, Value: round2(ema)})
+	}
+	return out
+}
+
+// RSI uses Wilder's smoothing.
+func RSI(cs []Candle, period int) []IndicatorPoint {
+	v := closes(cs)
+	if len(v) <= period {
+		return nil
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		d := v[i] - v[i-1]
+		if d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	rsi := func() float64 {
+		if loss == 0 {
+			if gain == 0 {
+				return 50
+			}
+			return 100
+		}
+		return 100 - 100/(1+gain/loss)
+	}
+	out := []IndicatorPoint{{Time: cs[period].Start, Value: round2(rsi())}}
+	for i := period + 1; i < len(v); i++ {
+		d := v[i] - v[i-1]
+		g, l := 0.0, 0.0
+		if d > 0 {
+			g = d
+		} else {
+			l = -d
+		}
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+		out = append(out, IndicatorPoint{Time: cs[i].Start, Value: round2(rsi())})
+	}
+	return out
+}
+
+// Bollinger returns the SMA band with k population standard deviations.
+func Bollinger(cs []Candle, period int, k float64) []IndicatorPoint {
+	v := closes(cs)
+	var out []IndicatorPoint
+	for i := period - 1; i < len(v); i++ {
+		win := v[i-period+1 : i+1]
+		mean := 0.0
+		for _, x := range win {
+			mean += x
+		}
+		mean /= float64(period)
+		variance := 0.0
+		for _, x := range win {
+			variance += (x - mean) * (x - mean)
+		}
+		sd := math.Sqrt(variance / float64(period))
+		out = append(out, IndicatorPoint{Time: cs[i].Start, Value: round2(mean), Middle: round2(mean), Upper: round2(mean + k*sd), Lower: round2(mean - k*sd)})
+	}
+	return out
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// computeIndicator takes a name already resolved by indicatorName.
+func computeIndicator(name string, cs []Candle, period int) ([]IndicatorPoint, error) {
+	switch name {
+	case "sma":
+		return SMA(cs, period), nil
+	case "ema":
+		return EMA(cs, period), nil
+	case "rsi":
+		return RSI(cs, period), nil
+	case "bollinger":
+		return Bollinger(cs, period, 2), nil
+	}
+	return nil, fmt.Errorf("indikator tidak dikenal: %q", name)
+}
+
+func lastValue(pts []IndicatorPoint) *float64 {
+	if len(pts) == 0 {
+		return nil
+	}
+	v := pts[len(pts)-1].Value
+	return &v
+}
+
+// rebuildIndicators recomputes the candles from every stored tick, after
+// ticks were replaced. Must be called with stateMutex held.
+func rebuildIndicators() {
+	interval, _ := parseInterval(indicatorInterval())
+	indicatorCandles, indicatorCandleInterval = BuildCandles(ticks, interval), interval
+	indicatorCandleTicks = len(ticks)
+	refreshIndicatorSnapshot()
+}
+
+// updateIndicators extends the candles with t, the tick just appended, and
+// takes out the ticks appendTick pruned: whole candles are dropped and a
+// partly pruned first candle is rebuilt from its remaining ticks. Must be
+// called with stateMutex held.
+func updateIndicators(t Tick) {
+	interval, _ := parseInterval(indicatorInterval())
+	if interval != indicatorCandleInterval {
+		rebuildIndicators()
+		return
+	}
+	indicatorCandles = addToCandles(indicatorCandles, t, interval)
+	pruned := indicatorCandleTicks + 1 - len(ticks)
+	for pruned > 0 && len(indicatorCandles) > 0 && indicatorCandles[0].Ticks <= pruned {
+		pruned -= indicatorCandles[0].Ticks
+		indicatorCandles = indicatorCandles[1:]
+	}
+	if pruned > 0 && len(indicatorCandles) > 0 {
+		indicatorCandles[0] = BuildCandles(ticks[:indicatorCandles[0].Ticks-pruned], interval)[0]
+	}
+	indicatorCandleTicks = len(ticks)
+	refreshIndicatorSnapshot()
+}
+
+// refreshIndicatorSnapshot sets state.Indicators from indicatorCandles.
+// SMA and Bollinger only look at their last period candles; EMA and RSI are
+// recursive and walk them all, which is linear in the retained candles.
+func refreshIndicatorSnapshot() {
+	cs := indicatorCandles
+	tail := func(period int) []Candle {
+		if len(cs) > period {
+			return cs[len(cs)-period:]
+		}
+		return cs
+	}
+	snap := IndicatorSnapshot{
+		Interval: indicatorInterval(),
+		SMA:      lastValue(SMA(tail(defaultPeriods["sma"]), defaultPeriods["sma"])),
+		EMA:      lastValue(EMA(cs, defaultPeriods["ema"])),
+		RSI:      lastValue(RSI(cs, defaultPeriods["rsi"])),
+	}
+	if bb := Bollinger(tail(defaultPeriods["bollinger"]), defaultPeriods["bollinger"], 2); len(bb) > 0 {
+		last := bb[len(bb)-1]
+		snap.BollUpper, snap.BollLower = &last.Upper, &last.Lower
+	}
+	state.Indicators = snap
+}
+
+func jsonError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func IndicatorsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := q.Get("name")
+	if name == "" {
+		name = "sma"
+	}
+	name, ok := indicatorName(name)
+	if !ok {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("indikator tidak dikenal: %q", q.Get("name")))
+		return
+	}
+	intervalName := q.Get("interval")
+	if intervalName == "" {
+		intervalName = indicatorInterval()
+	}
+	interval, err := parseInterval(intervalName)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	period := defaultPeriods[name]
+	if p, err := strconv.Atoi(q.Get("period")); err == nil {
+		period = p
+	}
+	if period < 2 || period > 500 {
+		jsonError(w, http.StatusBadRequest, "period harus 2..500")
+		return
+	}
+	stateMutex.RLock()
+	var cs []Candle
+	if interval == indicatorCandleInterval {
+		cs = append([]Candle(nil), indicatorCandles...)
+	} else {
+		cs = BuildCandles(ticks, interval)
+	}
+	var dataFrom, dataTo interface{}
+	if len(ticks) > 0 {
+		dataFrom, dataTo = ticks[0].UpdatedAt, ticks[len(ticks)-1].UpdatedAt
+	}
+	stateMutex.RUnlock()
+	pts, err := computeIndicator(name, cs, period)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pts == nil {
+		pts = []IndicatorPoint{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":      name,
+		"interval":  intervalName,
+		"period":    period,
+		"candles":   len(cs),
+		"data_from": dataFrom,
+		"data_to":   dataTo,
+		"points":    pts,
+	})
+}
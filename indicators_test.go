@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIndicatorsHandlerNames(t *testing.T) {
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusOK},
+		{"name=bb", http.StatusOK},
+		{"name=BOLLINGER", http.StatusOK},
+		{"name=rsi&period=7", http.StatusOK},
+		{"name=macd", http.StatusBadRequest},
+		{"name=sma&period=1", http.StatusBadRequest},
+		{"interval=10s", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		IndicatorsHandler(rec, httptest.NewRequest("GET", "/api/indicators?"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: status %d, want %d (%s)", tt.query, rec.Code, tt.code, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	IndicatorsHandler(rec, httptest.NewRequest("GET", "/api/indicators?name=macd", nil))
+	if want := "{\"error\":\"indikator tidak dikenal: \\\"macd\\\"\"}\n"; rec.Body.String() != want {
+		t.Errorf("body = %s, want %s", rec.Body, want)
+	}
+}
+
+func TestUpdateIndicatorsMatchesRebuild(t *testing.T) {
+	t.Setenv("TICK_RETENTION", "12h")
+	InitState()
+	defer InitState()
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	setTicks(nil)
+	rebuildIndicators()
+	start := time.Now().Add(-16 * time.Hour)
+	for i := 0; i < 16*60; i++ {
+		tk := Tick{BuyingRate: 1500000 + (i*7919)%5000, UpdatedAt: start.Add(time.Duration(i) * time.Minute)}
+		appendTick(tk)
+		updateIndicators(tk)
+	}
+	got, gotSnap := indicatorCandles, state.Indicators
+	rebuildIndicators()
+	if len(got) != len(indicatorCandles) {
+		t.Fatalf("%d incremental candles, %d rebuilt", len(got), len(indicatorCandles))
+	}
+	for i, c := range got {
+		r := indicatorCandles[i]
+		if !c.Start.Equal(r.Start) || c.Open != r.Open || c.High != r.High || c.Low != r.Low || c.Close != r.Close || c.Ticks != r.Ticks {
+			t.Errorf("candle %d: %+v, rebuilt %+v", i, c, r)
+		}
+	}
+	if *gotSnap.SMA != *state.Indicators.SMA || *gotSnap.BollUpper != *state.Indicators.BollUpper {
+		t.Errorf("snapshot %+v, rebuilt %+v", gotSnap, state.Indicators)
+	}
+	setTicks(nil)
+}
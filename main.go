@@ -1,20 +1,125 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// serveOptions picks which halves of the service run. Non-live sources
+// (sim, replay) run with persist off: they start from an empty state that
+// is never saved. Their position alerts go to alertChat, never to users.
+// follow replaces the fetchers with StartStateFollower so a web-only
+// process can sit next to a bot-only one without polling upstream twice or
+// overwriting the user data the bot saves.
+type serveOptions struct {
+	web       bool
+	bot       bool
+	persist   bool
+	alerts    bool
+	alertChat int64
+	follow    bool
+	port      string
+}
+
+func serve(o serveOptions) int {
+	if !sourceIsLive() {
+		// Simulated or recorded ticks would be rejected as out of order
+		// against the persisted history, or saved over it.
+		o.persist, o.alertChat = false, simAlertChat()
+		if o.alertChat == 0 {
+			o.alerts = false
+		}
+		slog.Warn("non-live price source: state is not loaded or saved", "position_alerts", o.alerts, "alert_chat", o.alertChat)
+	}
+	positionAlerts, positionAlertChat = o.alerts, o.alertChat
 	InitState()
-	go StartFetchers()
-	go StartTelegramBot()
-	http.Handle("/", http.FileServer(http.Dir("./static")))
-	http.HandleFunc("/api/state", ApiStateHandler)
-	http.HandleFunc("/ws", WsHandler)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
-	}
-	http.ListenAndServe(":"+port, nil)
+	if o.persist {
+		LoadState()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var wg sync.WaitGroup
+	run := func(f func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(ctx)
+		}()
+	}
+	if o.follow {
+		run(StartStateFollower)
+	} else {
+		run(StartFetchers)
+		run(StartFeedMonitor)
+	}
+	if o.bot {
+		run(StartTelegramBot)
+	}
+	run(StartAnnouncementScheduler)
+	if o.persist {
+		run(StartPersister)
+	}
+	if o.web {
+		run(StartWsPinger)
+	}
+	var srv *http.Server
+	if o.web {
+		http.Handle("/", http.FileServer(http.Dir("./static")))
+		http.HandleFunc("/api/state", ApiStateHandler)
+		http.HandleFunc("/api/usd_idr", UsdIdrHandler)
+		http.HandleFunc("/api/fx", FxHandler)
+		http.HandleFunc("/api/rejected", RejectedHandler)
+		http.HandleFunc("/api/indicators", IndicatorsHandler)
+		http.HandleFunc("/api/spread", SpreadHandler)
+		http.HandleFunc("/api/stats", StatsHandler)
+		http.HandleFunc("/api/backtest", BacktestHandler)
+		http.HandleFunc("/metrics", MetricsHandler)
+		http.HandleFunc("/healthz", HealthzHandler)
+		http.HandleFunc("/readyz", ReadyzHandler)
+		http.HandleFunc("/ws", WsHandler)
+		srv = &http.Server{Addr: ":" + o.port}
+		go func() {
+			slog.Info("http server listening", "port", o.port)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("http server stopped", "err", err)
+				stop()
+			}
+		}()
+	}
+	<-ctx.Done()
+	slog.Info("shutting down")
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("http shutdown incomplete", "err", err)
+		}
+	}
+	CloseWebSockets()
+	wg.Wait()
+	if c, ok := priceSource.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			slog.Warn("closing price source failed", "err", err)
+		}
+	}
+	if o.persist {
+		if err := SaveState(); err != nil {
+			slog.Error("final state flush failed", "path", stateFilePath(), "err", err)
+			return 1
+		}
+	}
+	slog.Info("shutdown complete")
+	return 0
 }
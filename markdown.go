@@ -0,0 +1,289 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	xhtml "golang.org/x/net/html"
+)
+
+var allowedTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "s": true,
+	"code": true, "pre": true, "br": true, "a": true,
+}
+
+var droppedContentTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+}
+
+var (
+	mdLink = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	// mdItalic needs a non-word character or the text edge around each *
+	// so that "2*3*4" stays arithmetic.
+	mdItalic = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*($|[^\w*])`)
+	mdStrike = regexp.MustCompile(`~~([^~]+)~~`)
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+)
+
+// RenderTreasuryInfo turns the text of an admin message into sanitized HTML.
+// Telegram formatting entities win over Markdown when the client sent any.
+// skip is the number of leading UTF-16 units to drop (the command itself).
+func RenderTreasuryInfo(text string, entities []tgbotapi.MessageEntity, skip int) string {
+	var out string
+	if hasFormattingEntities(entities) {
+		out = entitiesToHTML(text, entities, skip)
+	} else {
+		units := utf16.Encode([]rune(text))
+		if skip > len(units) {
+			skip = len(units)
+		}
+		out = markdownToHTML(string(utf16.Decode(units[skip:])))
+	}
+	return SanitizeHTML(strings.TrimSpace(out))
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func hasFormattingEntities(entities []tgbotapi.MessageEntity) bool {
+	for _, e := range entities {
+		switch e.Type {
+		case "bold", "italic", "underline", "strikethrough", "code", "pre", "text_link", "url":
+			return true
+		}
+	}
+	return false
+}
+
+func entityTags(e tgbotapi.MessageEntity, content string) (string, string) {
+	switch e.Type {
+	case "bold":
+		return "<b>", "</b>"
+	case "italic":
+		return "<i>", "</i>"
+	case "underline":
+		return "<u>", "</u>"
+	case "strikethrough":
+		return "<s>", "</s>"
+	case "code":
+		return "<code>", "</code>"
+	case "pre":
+		return "<pre>", "</pre>"
+	case "text_link":
+		return `<a href="` + html.EscapeString(e.URL) + `">`, "</a>"
+	case "url":
+		if !strings.Contains(content, "://") {
+			content = "https://" + content
+		}
+		return `<a href="` + html.EscapeString(content) + `">`, "</a>"
+	}
+	return "", ""
+}
+
+func entitiesToHTML(text string, entities []tgbotapi.MessageEntity, skip int) string {
+	units := utf16.Encode([]rune(text))
+	if skip > len(units) {
+		skip = len(units)
+	}
+	var ents []tgbotapi.MessageEntity
+	for _, e := range entities {
+		if e.Offset < skip || e.Offset+e.Length > len(units) || e.Length <= 0 {
+			continue
+		}
+		if o, _ := entityTags(e, ""); o == "" && e.Type != "url" {
+			continue
+		}
+		ents = append(ents, e)
+	}
+	sort.SliceStable(ents, func(i, j int) bool {
+		if ents[i].Offset != ents[j].Offset {
+			return ents[i].Offset < ents[j].Offset
+		}
+		return ents[i].Length > ents[j].Length
+	})
+	var b strings.Builder
+	var open []tgbotapi.MessageEntity
+	next := 0
+	for pos := skip; pos <= len(units); pos++ {
+		for len(open) > 0 && open[len(open)-1].Offset+open[len(open)-1].Length == pos {
+			e := open[len(open)-1]
+			_, c := entityTags(e, string(utf16.Decode(units[e.Offset:e.Offset+e.Length])))
+			b.WriteString(c)
+			open = open[:len(open)-1]
+		}
+		for next < len(ents) && ents[next].Offset == pos {
+			e := ents[next]
+			o, _ := entityTags(e, string(utf16.Decode(units[e.Offset:e.Offset+e.Length])))
+			b.WriteString(o)
+			open = append(open, e)
+			next++
+		}
+		if pos == len(units) {
+			break
+		}
+		end := pos + 1
+		if utf16.IsSurrogate(rune(units[pos])) && end < len(units) {
+			end++
+		}
+		b.WriteString(html.EscapeString(string(utf16.Decode(units[pos:end]))))
+		pos = end - 1
+	}
+	return b.String()
+}
+
+// markdownToHTML converts the small Markdown subset admins use. Code spans
+// and links are swapped for placeholders first so that emphasis markers
+// inside them, such as a * in a URL, are left alone.
+func markdownToHTML(src string) string {
+	var held []string
+	hold := func(html string) string {
+		held = append(held, html)
+		return "\x00" + strconv.Itoa(len(held)-1) + "\x00"
+	}
+	s := mdCode.ReplaceAllStringFunc(src, func(m string) string {
+		return hold("<code>" + html.EscapeString(mdCode.FindStringSubmatch(m)[1]) + "</code>")
+	})
+	s = html.EscapeString(s)
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		g := mdLink.FindStringSubmatch(m)
+		return hold(`<a href="` + g[2] + `">` + mdEmphasis(g[1]) + "</a>")
+	})
+	s = mdEmphasis(s)
+	for i := len(held) - 1; i >= 0; i-- {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", held[i], 1)
+	}
+	return s
+}
+
+func mdEmphasis(s string) string {
+	s = mdBold.ReplaceAllString(s, "<b>$1</b>")
+	s = mdStrike.ReplaceAllString(s, "<s>$1</s>")
+	// A second pass catches "*a* *b*", where the first match consumed the
+	// space the second needs.
+	for i := 0; i < 2; i++ {
+		s = mdItalic.ReplaceAllString(s, "$1<i>$2</i>$3")
+	}
+	return s
+}
+
+func safeHref(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "tg", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
+
+func escapeInfoText(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "  ", "&nbsp;&nbsp;")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// htmlToText flattens sanitized info HTML to plain text, with <br> as a
+// newline, for the raw state view.
+func htmlToText(s string) string {
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return strings.TrimSpace(strings.ReplaceAll(b.String(), "\u00a0", " "))
+		case xhtml.TextToken:
+			b.Write(z.Text())
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if name, _ := z.TagName(); string(name) == "br" {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// SanitizeHTML keeps only allowlisted tags; everything else is emitted as
+// escaped text, and the contents of script-like elements are dropped.
+func SanitizeHTML(in string) string {
+	z := xhtml.NewTokenizer(strings.NewReader(in))
+	var b strings.Builder
+	var stack []string
+	dropDepth := 0
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case xhtml.TextToken:
+			if dropDepth == 0 {
+				b.WriteString(escapeInfoText(tok.Data))
+			}
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if droppedContentTags[tok.Data] {
+				if tt == xhtml.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 || !allowedTags[tok.Data] {
+				continue
+			}
+			if tok.Data == "br" {
+				b.WriteString("<br>")
+				continue
+			}
+			if tok.Data == "a" {
+				href := ""
+				for _, a := range tok.Attr {
+					if a.Key == "href" {
+						href = a.Val
+					}
+				}
+				safe, ok := safeHref(href)
+				if !ok {
+					continue
+				}
+				b.WriteString(`<a href="` + html.EscapeString(safe) + `" target="_blank" rel="noopener noreferrer">`)
+			} else {
+				b.WriteString("<" + tok.Data + ">")
+			}
+			if tt == xhtml.StartTagToken {
+				stack = append(stack, tok.Data)
+			}
+		case xhtml.EndTagToken:
+			if droppedContentTags[tok.Data] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == tok.Data {
+					for j := len(stack) - 1; j >= i; j-- {
+						b.WriteString("</" + stack[j] + ">")
+					}
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString("</" + stack[i] + ">")
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed tags", "<b>tebal</b> <i>miring</i>", "<b>tebal</b> <i>miring</i>"},
+		{"script dropped", "a<script>alert(1)</script>b", "ab"},
+		{"style dropped", "<style>body{}</style>ok", "ok"},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, "x"},
+		{"data href", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, "x"},
+		{"safe href", `<a href="https://treasury.id">t</a>`, `<a href="https://treasury.id" target="_blank" rel="noopener noreferrer">t</a>`},
+		{"href attribute injection", `<a href="https://x.id/&quot; onclick=&quot;alert(1)">t</a>`, `<a href="https://x.id/%22%20onclick=%22alert%281%29" target="_blank" rel="noopener noreferrer">t</a>`},
+		{"event handler stripped", `<b onclick="alert(1)">x</b>`, "<b>x</b>"},
+		{"unknown tag escaped", `<img src=x onerror=alert(1)>t`, "t"},
+		{"unclosed tag closed", "<b>x", "<b>x</b>"},
+		{"text escaped", "1 < 2 & 3", "1 &lt; 2 &amp; 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.in); got != tt.want {
+				t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToHTMLSanitized(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bold link", "**Info** [web](https://treasury.id)", `<b>Info</b> <a href="https://treasury.id" target="_blank" rel="noopener noreferrer">web</a>`},
+		{"javascript link", "[klik](javascript:alert(1))", "klik)"},
+		{"raw script", "<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"quote injection", `[x](https://a.id/"onmouseover="alert(1))`, `<a href="https://a.id/%22onmouseover=%22alert%281" target="_blank" rel="noopener noreferrer">x</a>)`},
+		{"star in url", "[a](https://x.com/a*b*c)", `<a href="https://x.com/a*b*c" target="_blank" rel="noopener noreferrer">a</a>`},
+		{"bold link text", "[**a**](https://x.com)", `<a href="https://x.com" target="_blank" rel="noopener noreferrer"><b>a</b></a>`},
+		{"arithmetic", "2*3*4", "2*3*4"},
+		{"two italics", "*a* *b*", "<i>a</i> <i>b</i>"},
+		{"star in code", "`a*b*c` *d*", "<code>a*b*c</code> <i>d</i>"},
+		{"many code spans", "`0``1``2``3``4``5``6``7``8``9``10``11`", "<code>0</code><code>1</code><code>2</code><code>3</code><code>4</code><code>5</code><code>6</code><code>7</code><code>8</code><code>9</code><code>10</code><code>11</code>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(markdownToHTML(tt.in)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"<b>Info</b><br><br>📢 <i>libur</i>", "Info\n\n📢 libur"},
+		{"1 &lt; 2 &amp;&nbsp;&nbsp;3", "1 < 2 &  3"},
+		{`<a href="https://treasury.id" target="_blank">web</a>`, "web"},
+	}
+	for _, tt := range tests {
+		if got := htmlToText(tt.in); got != tt.want {
+			t.Errorf("htmlToText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEntitiesToHTML(t *testing.T) {
+	ent := func(typ string, off, n int) tgbotapi.MessageEntity {
+		return tgbotapi.MessageEntity{Type: typ, Offset: off, Length: n}
+	}
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		skip     int
+		want     string
+	}{
+		{"plain bold", "/atur harga naik", []tgbotapi.MessageEntity{ent("bot_command", 0, 5), ent("bold", 6, 5)}, 6, "<b>harga</b> naik"},
+		// 📢 is two UTF-16 units, so "info" starts at offset 3.
+		{"surrogate pair", "📢 info & <b>", []tgbotapi.MessageEntity{ent("bold", 3, 4)}, 0, "📢 <b>info</b> &amp; &lt;b&gt;"},
+		{"emoji inside entity", "a 🚀🚀 b", []tgbotapi.MessageEntity{ent("italic", 2, 4)}, 0, "a <i>🚀🚀</i> b"},
+		{"nested", "tebal miring", []tgbotapi.MessageEntity{ent("bold", 0, 12), ent("italic", 6, 6)}, 0, "<b>tebal <i>miring</i></b>"},
+		{"same start", "ab", []tgbotapi.MessageEntity{ent("italic", 0, 1), ent("bold", 0, 2)}, 0, "<b><i>a</i>b</b>"},
+		{"text link", "lihat web", []tgbotapi.MessageEntity{{Type: "text_link", Offset: 6, Length: 3, URL: "https://treasury.id/?a=1&b=2"}}, 0, `lihat <a href="https://treasury.id/?a=1&amp;b=2">web</a>`},
+		{"url entity", "buka treasury.id", []tgbotapi.MessageEntity{ent("url", 5, 11)}, 0, `buka <a href="https://treasury.id">treasury.id</a>`},
+		{"entity before skip dropped", "/atur x", []tgbotapi.MessageEntity{ent("bold", 0, 5)}, 6, "x"},
+		{"entity past end dropped", "abc", []tgbotapi.MessageEntity{ent("bold", 1, 5)}, 0, "abc"},
+		{"skip past end", "/atur", nil, 9, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entitiesToHTML(tt.text, tt.entities, tt.skip); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal Prometheus text-format registry; the service only needs
+// counters, gauges and one histogram family, so no client library.
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	values  map[string]float64
+	hists   map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var (
+	metricsMutex    sync.Mutex
+	metricFamilies  []*metricFamily
+	fetchBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5}
+	mFetchTotal     = newMetric("goldmonitor_fetch_total", "Upstream fetch attempts.", "counter", "source")
+	mFetchFailures  = newMetric("goldmonitor_fetch_failures_total", "Upstream fetch failures by reason.", "counter", "source", "reason")
+	mFetchStatus    = newMetric("goldmonitor_fetch_status_total", "Upstream HTTP status codes.", "counter", "source", "code")
+	mFetchDuration  = newHistogram("goldmonitor_fetch_duration_seconds", "Upstream fetch latency.", fetchBuckets, "source")
+	mTicks          = newMetric("goldmonitor_ticks_total", "New gold price ticks appended to history.", "counter")
+	mOutOfOrder     = newMetric("goldmonitor_ticks_out_of_order_total", "Treasury ticks rejected for an updated_at older than the last tick.", "counter")
+	mFxUpdates      = newMetric("goldmonitor_fx_updates_total", "New FX quotes appended to history.", "counter", "pair")
+	mWsConnections  = newMetric("goldmonitor_ws_connections", "Open WebSocket connections.", "gauge")
+	mWsConnects     = newMetric("goldmonitor_ws_connects_total", "Accepted WebSocket connections.", "counter")
+	mWsRejected     = newMetric("goldmonitor_ws_rejected_total", "WebSocket connections rejected at capacity.", "counter")
+	mWsDrops        = newMetric("goldmonitor_ws_drops_total", "WebSocket connections closed.", "counter")
+	mWsSlowSkips    = newMetric("goldmonitor_ws_slow_client_skips_total", "Broadcasts skipped because a client send buffer was full.", "counter")
+	mBotCommands    = newMetric("goldmonitor_telegram_commands_total", "Telegram commands received.", "counter", "command", "status")
+	mLastBuy        = newMetric("goldmonitor_last_buying_rate", "Latest Treasury buying rate (IDR/gram).", "gauge")
+	mLastSell       = newMetric("goldmonitor_last_selling_rate", "Latest Treasury selling rate (IDR/gram).", "gauge")
+	mLastFx         = newMetric("goldmonitor_last_fx_rate", "Latest FX rate per pair.", "gauge", "pair")
+	mLastTickUnixTs = newMetric("goldmonitor_last_tick_timestamp_seconds", "Unix time of the latest gold tick.", "gauge")
+)
+
+func newMetric(name, help, kind string, labels ...string) *metricFamily {
+	f := &metricFamily{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	metricFamilies = append(metricFamilies, f)
+	return f
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metricFamily {
+	f := newMetric(name, help, "histogram", labels...)
+	f.buckets = buckets
+	f.hists = make(map[string]*histogram)
+	return f
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func (f *metricFamily) Add(v float64, labels ...string) {
+	metricsMutex.Lock()
+	f.values[labelKey(labels)] += v
+	metricsMutex.Unlock()
+}
+
+func (f *metricFamily) Inc(labels ...string) {
+	f.Add(1, labels...)
+}
+
+func (f *metricFamily) Set(v float64, labels ...string) {
+	metricsMutex.Lock()
+	f.values[labelKey(labels)] = v
+	metricsMutex.Unlock()
+}
+
+func (f *metricFamily) Observe(v float64, labels ...string) {
+	metricsMutex.Lock()
+	k := labelKey(labels)
+	h := f.hists[k]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(f.buckets))}
+		f.hists[k] = h
+	}
+	for i, b := range f.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+	metricsMutex.Unlock()
+}
+
+// observeFetch records one upstream attempt. code is 0 when no response was
+// received; failure is empty on success, which also feeds /readyz.
+func observeFetch(source string, start time.Time, code int, failure string, err error) {
+	latency := time.Since(start)
+	mFetchTotal.Inc(source)
+	mFetchDuration.Observe(latency.Seconds(), source)
+	if code != 0 {
+		mFetchStatus.Inc(source, strconv.Itoa(code))
+	}
+	if failure != "" {
+		mFetchFailures.Inc(source, failure)
+		slog.Warn("fetch failed", "source", source, "reason", failure, "status", code, "latency_ms", latency.Milliseconds(), "err", err)
+		return
+	}
+	slog.Debug("fetch ok", "source", source, "status", code, "latency_ms", latency.Milliseconds())
+	markSourceSuccess(source)
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	var parts []string
+	for i, n := range names {
+		if i < len(values) {
+			parts = append(parts, n+`="`+escapeLabel(values[i])+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	metricsMutex.Lock()
+	for _, f := range metricFamilies {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		if f.kind == "histogram" {
+			keys := make([]string, 0, len(f.hists))
+			for k := range f.hists {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				h := f.hists[k]
+				lv := strings.Split(k, "\xff")
+				for i, bound := range f.buckets {
+					fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, lv, "le", formatFloat(bound)), h.counts[i])
+				}
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, lv, "le", "+Inf"), h.count)
+				fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, formatLabels(f.labels, lv), formatFloat(h.sum))
+				fmt.Fprintf(&b, "%s_count%s %d\n", f.name, formatLabels(f.labels, lv), h.count)
+			}
+			continue
+		}
+		keys := make([]string, 0, len(f.values))
+		for k := range f.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 && len(f.labels) == 0 {
+			keys = append(keys, "")
+		}
+		for _, k := range keys {
+			var lv []string
+			if len(f.labels) > 0 {
+				lv = strings.Split(k, "\xff")
+			}
+			fmt.Fprintf(&b, "%s%s %s\n", f.name, formatLabels(f.labels, lv), formatFloat(f.values[k]))
+		}
+	}
+	metricsMutex.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type persistedState struct {
+	Ticks         []Tick               `json:"ticks"`
+	FxHistory     map[string][]FxQuote `json:"fx_history"`
+	Portfolios    map[int64][]Lot      `json:"portfolios,omitempty"`
+	Positions     map[int64][]Position `json:"positions,omitempty"`
+	Announcements []Announcement       `json:"announcements,omitempty"`
+	NextAnnounce  int                  `json:"next_announce_id,omitempty"`
+}
+
+var (
+	stateDirty bool
+	// stateFileFlag is set by the -state flag and wins over STATE_FILE.
+	stateFileFlag string
+)
+
+func stateFilePath() string {
+	if stateFileFlag != "" {
+		return stateFileFlag
+	}
+	if p := os.Getenv("STATE_FILE"); p != "" {
+		return p
+	}
+	return "data/state.json"
+}
+
+// markDirty must be called with stateMutex held.
+func markDirty() {
+	stateDirty = true
+}
+
+// readStateFile decodes a state file with its ticks sorted by updated_at.
+func readStateFile(path string) (persistedState, error) {
+	var p persistedState
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, err
+	}
+	if p.FxHistory == nil {
+		p.FxHistory = make(map[string][]FxQuote)
+	}
+	sort.SliceStable(p.Ticks, func(i, j int) bool { return p.Ticks[i].UpdatedAt.Before(p.Ticks[j].UpdatedAt) })
+	return p, nil
+}
+
+func LoadState() {
+	p, err := readStateFile(stateFilePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("load state failed", "path", stateFilePath(), "err", err)
+		}
+		return
+	}
+	stateMutex.Lock()
+	setTicks(p.Ticks)
+	rebuildIndicators()
+	updateSpread()
+	fxLog = p.FxHistory
+	if p.Portfolios != nil {
+		portfolios = p.Portfolios
+	}
+	if p.Positions != nil {
+		positions = p.Positions
+	}
+	announcements = p.Announcements
+	nextAnnounceID = p.NextAnnounce
+	for _, a := range announcements {
+		if a.ID >= nextAnnounceID {
+			nextAnnounceID = a.ID + 1
+		}
+	}
+	if nextAnnounceID < 1 {
+		nextAnnounceID = 1
+	}
+	for pair := range fxLog {
+		syncFxState(pair)
+	}
+	stateMutex.Unlock()
+}
+
+func SaveState() error {
+	stateMutex.Lock()
+	p := persistedState{
+		Ticks:         append([]Tick(nil), ticks...),
+		FxHistory:     make(map[string][]FxQuote, len(fxLog)),
+		Portfolios:    make(map[int64][]Lot, len(portfolios)),
+		Positions:     make(map[int64][]Position, len(positions)),
+		Announcements: append([]Announcement(nil), announcements...),
+		NextAnnounce:  nextAnnounceID,
+	}
+	for user, ps := range positions {
+		p.Positions[user] = append([]Position(nil), ps...)
+	}
+	for user, lots := range portfolios {
+		p.Portfolios[user] = append([]Lot(nil), lots...)
+	}
+	for pair, h := range fxLog {
+		p.FxHistory[pair] = append([]FxQuote(nil), h...)
+	}
+	stateDirty = false
+	stateMutex.Unlock()
+	return writeStateFile(stateFilePath(), p)
+}
+
+// writeStateFile replaces path atomically via a temporary file.
+func writeStateFile(path string, p persistedState) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// StartStateFollower reloads the state file whenever another process
+// (serve or bot-only) has rewritten it. It is the only data source of a
+// web-only process, which neither polls upstream nor saves.
+func StartStateFollower(ctx context.Context) {
+	var mod time.Time
+	for {
+		if fi, err := os.Stat(stateFilePath()); err == nil {
+			// Readiness here means the writer's file is readable.
+			markSourceSuccess("treasury")
+			markSourceSuccess("google:" + primaryFxPair)
+			if !fi.ModTime().Equal(mod) {
+				mod = fi.ModTime()
+				LoadState()
+				BroadcastState()
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("state file not readable", "path", stateFilePath(), "err", err)
+		}
+		if !sleepCtx(ctx, envDuration("STATE_FOLLOW_INTERVAL", 2*time.Second)) {
+			return
+		}
+	}
+}
+
+// StartPersister saves dirty state every 10s until ctx is done; the final
+// flush on shutdown is left to the caller.
+func StartPersister(ctx context.Context) {
+	for sleepCtx(ctx, 10*time.Second) {
+		stateMutex.RLock()
+		dirty := stateDirty
+		stateMutex.RUnlock()
+		if dirty {
+			if err := SaveState(); err != nil {
+				slog.Error("save state failed", "path", stateFilePath(), "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type statusError struct {
+	Code       int
+	RetryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Code)
+}
+
+func newStatusError(resp *SourceResponse) *statusError {
+	return &statusError{Code: resp.Code, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay-seconds and
+// an HTTP-date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// marketOpen reports whether t falls inside MARKET_HOURS (WIB, default
+// 06:00-23:59) on a MARKET_DAYS weekday (default Mon-Fri, as ISO numbers
+// 1-7) that is not listed in HOLIDAYS (comma separated YYYY-MM-DD).
+func marketOpen(t time.Time) bool {
+	t = t.In(wib())
+	for _, h := range strings.Split(os.Getenv("HOLIDAYS"), ",") {
+		if strings.TrimSpace(h) == t.Format("2006-01-02") {
+			return false
+		}
+	}
+	days := os.Getenv("MARKET_DAYS")
+	if days == "" {
+		days = "1-5"
+	}
+	wd := int(t.Weekday())
+	if wd == 0 {
+		wd = 7
+	}
+	if !inDayRange(days, wd) {
+		return false
+	}
+	hours := os.Getenv("MARKET_HOURS")
+	if hours == "" {
+		hours = "06:00-23:59"
+	}
+	parts := strings.SplitN(hours, "-", 2)
+	if len(parts) != 2 {
+		return true
+	}
+	from, err1 := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	to, err2 := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	fm, tm := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+	if fm <= tm {
+		return m >= fm && m <= tm
+	}
+	return m >= fm || m <= tm
+}
+
+func inDayRange(spec string, wd int) bool {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			a, err1 := strconv.Atoi(lo)
+			b, err2 := strconv.Atoi(hi)
+			if err1 == nil && err2 == nil && wd >= a && wd <= b {
+				return true
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(part); err == nil && n == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// poller decides how long to wait before the next fetch of one source.
+// Without marketHours it polls at interval around the clock.
+type poller struct {
+	interval    time.Duration
+	offHours    time.Duration
+	maxBackoff  time.Duration
+	marketHours bool
+	failures    int
+}
+
+func newPoller(interval time.Duration) *poller {
+	factor := 20
+	if n, err := strconv.Atoi(os.Getenv("POLL_OFF_HOURS_FACTOR")); err == nil && n > 0 {
+		factor = n
+	}
+	return &poller{
+		interval:    interval,
+		offHours:    interval * time.Duration(factor),
+		maxBackoff:  envDuration("POLL_MAX_BACKOFF", time.Minute),
+		marketHours: sourceIsLive(),
+	}
+}
+
+// next returns the delay after a fetch that ended with err. Failures back
+// off exponentially with jitter up to maxBackoff, and a Retry-After from the
+// upstream is never undercut.
+func (p *poller) next(err error, now time.Time) time.Duration {
+	base := p.interval
+	if p.marketHours && !marketOpen(now) {
+		base = p.offHours
+	}
+	if err == nil {
+		p.failures = 0
+		return base
+	}
+	p.failures++
+	d := base
+	for i := 0; i < p.failures && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	var se *statusError
+	if errors.As(err, &se) {
+		if se.RetryAfter > d {
+			d = se.RetryAfter
+		} else if se.Code == http.StatusTooManyRequests && d < p.maxBackoff/2 {
+			d = p.maxBackoff / 2
+		}
+	}
+	return d
+}
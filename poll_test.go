@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarketOpen(t *testing.T) {
+	// 2026-10-19 is a Monday and 2026-10-24 a Saturday.
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, wib()) }
+	tests := []struct {
+		name        string
+		hours, days string
+		holidays    string
+		t           time.Time
+		want        bool
+	}{
+		{"default open", "", "", "", at(19, 6, 0), true},
+		{"default before open", "", "", "", at(19, 5, 59), false},
+		{"default saturday", "", "", "", at(24, 12, 0), false},
+		{"utc input in wib", "", "", "", time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC), true},
+		{"holiday", "", "", "2026-10-01, 2026-10-19", at(19, 12, 0), false},
+		{"day list", "", "1,3,6", "", at(24, 12, 0), true},
+		{"day list excludes", "", "1,3,6", "", at(20, 12, 0), false},
+		{"midnight evening", "22:00-02:00", "1-7", "", at(19, 23, 0), true},
+		{"midnight morning", "22:00-02:00", "1-7", "", at(20, 1, 30), true},
+		{"midnight end inclusive", "22:00-02:00", "1-7", "", at(20, 2, 0), true},
+		{"midnight after close", "22:00-02:00", "1-7", "", at(20, 2, 1), false},
+		{"midnight daytime", "22:00-02:00", "1-7", "", at(19, 12, 0), false},
+		// The weekday is that of the calendar date, not of the session start.
+		{"midnight into saturday", "22:00-02:00", "1-5", "", at(24, 1, 30), false},
+		{"bad hours means open", "pagi", "1-7", "", at(19, 3, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MARKET_HOURS", tt.hours)
+			t.Setenv("MARKET_DAYS", tt.days)
+			t.Setenv("HOLIDAYS", tt.holidays)
+			if got := marketOpen(tt.t); got != tt.want {
+				t.Errorf("marketOpen(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollerNext(t *testing.T) {
+	t.Setenv("MARKET_HOURS", "")
+	t.Setenv("MARKET_DAYS", "")
+	t.Setenv("HOLIDAYS", "")
+	open := time.Date(2026, 10, 19, 12, 0, 0, 0, wib())
+	closed := time.Date(2026, 10, 18, 12, 0, 0, 0, wib())
+	fail := errors.New("boom")
+	tests := []struct {
+		name        string
+		marketHours bool
+		failures    int
+		err         error
+		now         time.Time
+		min, max    time.Duration
+	}{
+		{"success", true, 3, nil, open, 10 * time.Second, 10 * time.Second},
+		{"off hours", true, 0, nil, closed, 200 * time.Second, 200 * time.Second},
+		{"no schedule for replay", false, 0, nil, closed, 10 * time.Second, 10 * time.Second},
+		{"first failure", true, 0, fail, open, 10 * time.Second, 20 * time.Second},
+		{"backoff capped", true, 5, fail, open, 30 * time.Second, time.Minute},
+		{"off hours failure capped", true, 0, fail, closed, 30 * time.Second, time.Minute},
+		{"retry-after honoured", true, 0, &statusError{Code: 503, RetryAfter: 90 * time.Second}, open, 90 * time.Second, 90 * time.Second},
+		{"short retry-after", true, 5, &statusError{Code: 503, RetryAfter: time.Second}, open, 30 * time.Second, time.Minute},
+		{"429 floor", true, 0, &statusError{Code: http.StatusTooManyRequests}, open, 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &poller{interval: 10 * time.Second, offHours: 200 * time.Second, maxBackoff: time.Minute, marketHours: tt.marketHours, failures: tt.failures}
+			for i := 0; i < 20; i++ {
+				p.failures = tt.failures
+				if d := p.next(tt.err, tt.now); d < tt.min || d > tt.max {
+					t.Fatalf("next = %v, want within [%v, %v]", d, tt.min, tt.max)
+				}
+			}
+			if tt.err == nil && p.failures != 0 {
+				t.Errorf("failures = %d after success, want 0", p.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lot is one purchase recorded by a user with /beli. Harga is the price per
+// gram actually paid, Modal the rupiah spent.
+type Lot struct {
+	ID     int       `json:"id"`
+	Gram   float64   `json:"gram"`
+	Harga  int       `json:"harga"`
+	Modal  int       `json:"modal"`
+	Bought time.Time `json:"bought"`
+}
+
+// portfolios is guarded by stateMutex and persisted with the ticks.
+var portfolios = make(map[int64][]Lot)
+
+// maxLotGram bounds a single /beli so a mistyped amount cannot record a
+// lot worth billions.
+const maxLotGram = 1000
+
+// parseBeliAmount reads the first /beli argument. Grams need a g/gr/gram
+// suffix; anything else is rupiah as accepted by parseRupiah. A bare number
+// below 1000 is rejected as ambiguous.
+func parseBeliAmount(s string) (gram float64, rupiah int, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, suf := range []string{"gram", "gr", "g"} {
+		if strings.HasSuffix(s, suf) {
+			g, ok := parseGram(strings.TrimSuffix(s, suf))
+			if !ok {
+				return 0, 0, errors.New("jumlah gram tidak valid")
+			}
+			if g > maxLotGram {
+				return 0, 0, fmt.Errorf("maksimal %dgr per pembelian", maxLotGram)
+			}
+			return g, 0, nil
+		}
+	}
+	v, ok := parseRupiah(s)
+	if !ok {
+		return 0, 0, errors.New("jumlah tidak valid, contoh: 2.5gr, 500rb atau 1jt")
+	}
+	if v < 1000 && !strings.HasPrefix(s, "rp") {
+		return 0, 0, errors.New("jumlah ambigu, tulis 2.5gr untuk gram atau 500rb untuk rupiah")
+	}
+	return 0, v, nil
+}
+
+// parseGram reads a weight where a lone "." or "," is always the decimal
+// point, so "1,250" is 1.25 g rather than parseRate's thousands reading.
+func parseGram(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.Trim(s, "0123456789.,") != "" {
+		return 0, false
+	}
+	if strings.Count(s, ".")+strings.Count(s, ",") > 1 {
+		return parseRate(s)
+	}
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// AddLot records a purchase for user. harga 0 means the latest Treasury
+// buying rate.
+func AddLot(user int64, amount, harga string, now time.Time) (Lot, error) {
+	gram, rupiah, err := parseBeliAmount(amount)
+	if err != nil {
+		return Lot{}, err
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	price := 0
+	if harga != "" {
+		v, ok := parseRupiah(harga)
+		if lo, hi := treasuryRateBounds(); !ok || float64(v) < lo || float64(v) > hi {
+			return Lot{}, fmt.Errorf("harga per gram tidak valid (Rp%s - Rp%s)", formatRupiah(int(lo)), formatRupiah(int(hi)))
+		}
+		price = v
+	} else if t, ok := lastTick(); ok {
+		price = t.BuyingRate
+	}
+	if price <= 0 {
+		return Lot{}, errors.New("harga belum tersedia, sertakan harga beli")
+	}
+	lot := Lot{Harga: price, Bought: now}
+	if gram > 0 {
+		lot.Gram = gram
+		lot.Modal = int(math.Round(gram * float64(price)))
+	} else {
+		lot.Modal = rupiah
+		lot.Gram = float64(rupiah) / float64(price)
+		if lot.Gram > maxLotGram {
+			return Lot{}, fmt.Errorf("maksimal %dgr per pembelian", maxLotGram)
+		}
+	}
+	lots := portfolios[user]
+	for _, l := range lots {
+		if l.ID >= lot.ID {
+			lot.ID = l.ID + 1
+		}
+	}
+	if lot.ID == 0 {
+		lot.ID = 1
+	}
+	portfolios[user] = append(lots, lot)
+	markDirty()
+	return lot, nil
+}
+
+// RemoveLots deletes lot id, or every lot when id is 0, and returns what
+// was removed.
+func RemoveLots(user int64, id int) []Lot {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	var kept, removed []Lot
+	for _, l := range portfolios[user] {
+		if id == 0 || l.ID == id {
+			removed = append(removed, l)
+		} else {
+			kept = append(kept, l)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if len(kept) == 0 {
+		delete(portfolios, user)
+	} else {
+		portfolios[user] = kept
+	}
+	markDirty()
+	return removed
+}
+
+func userLots(user int64) ([]Lot, Tick, bool) {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	t, ok := lastTick()
+	return append([]Lot(nil), portfolios[user]...), t, ok
+}
+
+func formatGram(g float64) string {
+	return strings.TrimRight(strings.TrimRight(strconv.FormatFloat(g, 'f', 4, 64), "0"), ".")
+}
+
+func formatLot(l Lot, sell int) string {
+	line := fmt.Sprintf("#%d • %sgr @Rp%s (Rp%s) %s", l.ID, formatGram(l.Gram), formatRupiah(l.Harga), formatRupiah(l.Modal), l.Bought.In(wib()).Format("02/01 15:04"))
+	if sell > 0 {
+		line += "\n   " + calcProfit(l.Harga, sell, l.Modal, l.Modal)
+	}
+	return line
+}
+
+func formatPortfolio(lots []Lot, t Tick, ok bool) string {
+	if len(lots) == 0 {
+		return "📭 Portfolio kosong.\nCatat pembelian dengan /beli &lt;gram|rupiah&gt; &lt;harga&gt;\nContoh: /beli 2.5gr 1.450.000 atau /beli 1jt"
+	}
+	sort.Slice(lots, func(i, j int) bool { return lots[i].ID < lots[j].ID })
+	sell := 0
+	if ok {
+		sell = t.SellingRate
+	}
+	var b strings.Builder
+	b.WriteString("💼 <b>Portfolio Emas</b>\n━━━━━━━━━━━━━━━━━━━\n")
+	var gram float64
+	var modal int
+	for _, l := range lots {
+		b.WriteString(formatLot(l, sell) + "\n")
+		gram += l.Gram
+		modal += l.Modal
+	}
+	b.WriteString("━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(&b, "⚖️ Total: %sgr\n💵 Modal: Rp%s\n📊 Rata-rata: Rp%s/gr\n", formatGram(gram), formatRupiah(modal), formatRupiah(int(math.Round(float64(modal)/gram))))
+	if sell > 0 {
+		value := int(gram * float64(sell))
+		fmt.Fprintf(&b, "🔴 Harga jual: Rp%s\n💰 Nilai: Rp%s\n", formatRupiah(sell), formatRupiah(value))
+		switch pl := value - modal; {
+		case pl > 0:
+			fmt.Fprintf(&b, "📈 P/L: +Rp%s 🟢", formatRupiah(pl))
+		case pl < 0:
+			fmt.Fprintf(&b, "📉 P/L: -Rp%s 🔴", formatRupiah(-pl))
+		default:
+			b.WriteString("➖ P/L: Rp0")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBeliAmount(t *testing.T) {
+	tests := []struct {
+		in     string
+		gram   float64
+		rupiah int
+		ok     bool
+	}{
+		{"2.5gr", 2.5, 0, true},
+		{"1,250g", 1.25, 0, true},
+		{"3gram", 3, 0, true},
+		{"1jt", 0, 1000000, true},
+		{"500rb", 0, 500000, true},
+		{"1.000.000", 0, 1000000, true},
+		{"Rp500", 0, 500, true},
+		{"750", 0, 0, false},
+		{"2000gr", 0, 0, false},
+		{"1x", 0, 0, false},
+		{"abc", 0, 0, false},
+		{"gr", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			gram, rupiah, err := parseBeliAmount(tt.in)
+			if (err == nil) != tt.ok || gram != tt.gram || rupiah != tt.rupiah {
+				t.Errorf("parseBeliAmount(%q) = %v, %v, %v; want %v, %v, ok=%v", tt.in, gram, rupiah, err, tt.gram, tt.rupiah, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAddLotPrice(t *testing.T) {
+	const user = -1
+	defer RemoveLots(user, 0)
+	tests := []struct {
+		amount, harga string
+		harga0        int
+		ok            bool
+	}{
+		{"1gr", "1.450.000", 1450000, true},
+		{"1gr", "1,45jt", 1450000, true},
+		{"1gr", "1450rb", 1450000, true},
+		{"1gr", "1", 0, false},
+		{"1gr", "99jt", 0, false},
+		{"1gr", "murah", 0, false},
+		{"300jt", "200rb", 0, false},
+	}
+	for _, tt := range tests {
+		lot, err := AddLot(user, tt.amount, tt.harga, time.Now())
+		if (err == nil) != tt.ok || (tt.ok && lot.Harga != tt.harga0) {
+			t.Errorf("AddLot(%q, %q) = %+v, %v; want harga %d, ok=%v", tt.amount, tt.harga, lot, err, tt.harga0, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Position is a (modal, pokok) pair registered with /target and valued on
+// every tick the same way as the Jt20..Jt50 columns. Target and StopLoss
+// are rupiah amounts; StopLoss 0 disables the lower bound.
+//
+// AboveTarget and BelowStop record that the value started past a bound, so
+// that bound only alerts after the value has come back and crossed it.
+// Watched is false until those sides are known.
+type Position struct {
+	ID          int        `json:"id"`
+	ChatID      int64      `json:"chat_id"`
+	Modal       int        `json:"modal"`
+	Pokok       int        `json:"pokok"`
+	Target      int        `json:"target"`
+	StopLoss    int        `json:"stop_loss,omitempty"`
+	Created     time.Time  `json:"created"`
+	TargetHit   *time.Time `json:"target_hit,omitempty"`
+	StopHit     *time.Time `json:"stop_hit,omitempty"`
+	AboveTarget bool       `json:"above_target,omitempty"`
+	BelowStop   bool       `json:"below_stop,omitempty"`
+	Watched     bool       `json:"watched,omitempty"`
+}
+
+type positionAlert struct {
+	User   int64
+	ID     int
+	Stop   bool
+	At     time.Time
+	ChatID int64
+	Text   string
+}
+
+var (
+	// positions is guarded by stateMutex and persisted with the ticks.
+	positions = make(map[int64][]Position)
+	// positionAlerts and positionAlertChat are set once before the
+	// fetchers start. A non-zero positionAlertChat receives every alert
+	// instead of the position's owner, so simulated prices never reach
+	// real users.
+	positionAlerts    = true
+	positionAlertChat int64
+)
+
+// parseRupiah accepts plain or dotted amounts with an optional Rp prefix
+// and jt/rb/k suffix, e.g. "20jt", "Rp19.314.000" or "500rb". Any other
+// letter makes the amount invalid.
+func parseRupiah(s string) (int, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := 1.0
+	for suf, m := range map[string]float64{"jt": 1e6, "rb": 1e3, "k": 1e3} {
+		if strings.HasSuffix(s, suf) {
+			s, mult = strings.TrimSuffix(s, suf), m
+			break
+		}
+	}
+	s = strings.TrimSpace(strings.TrimPrefix(s, "rp"))
+	if s == "" || strings.Trim(s, "0123456789.,") != "" {
+		return 0, false
+	}
+	v, ok := parseRate(s)
+	if !ok {
+		return 0, false
+	}
+	return int(math.Round(v * mult)), true
+}
+
+func AddPosition(user, chatID int64, modal, pokok, target, stopLoss int, now time.Time) (Position, error) {
+	if modal <= 0 || pokok <= 0 || target <= 0 || stopLoss < 0 {
+		return Position{}, errors.New("nilai harus lebih dari 0")
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	p := Position{ID: 1, ChatID: chatID, Modal: modal, Pokok: pokok, Target: target, StopLoss: stopLoss, Created: now}
+	if t, ok := lastTick(); ok {
+		watchPosition(&p, t)
+	}
+	for _, q := range positions[user] {
+		if q.ID >= p.ID {
+			p.ID = q.ID + 1
+		}
+	}
+	positions[user] = append(positions[user], p)
+	markDirty()
+	return p, nil
+}
+
+func RemovePosition(user int64, id int) bool {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	ps := positions[user]
+	for i, p := range ps {
+		if p.ID == id {
+			ps = append(ps[:i:i], ps[i+1:]...)
+			if len(ps) == 0 {
+				delete(positions, user)
+			} else {
+				positions[user] = ps
+			}
+			markDirty()
+			return true
+		}
+	}
+	return false
+}
+
+func userPositions(user int64) ([]Position, Tick, bool) {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	t, ok := lastTick()
+	return append([]Position(nil), positions[user]...), t, ok
+}
+
+// watchPosition records which side of its bounds p starts on at t.
+func watchPosition(p *Position, t Tick) {
+	val := profitValue(t.BuyingRate, t.SellingRate, p.Modal, p.Pokok)
+	p.AboveTarget = val >= p.Target
+	p.BelowStop = p.StopLoss > 0 && val <= -p.StopLoss
+	p.Watched = true
+}
+
+// checkPositions returns an alert for every position whose value crossed
+// its target or stop-loss on t and was not yet delivered. A bound the value
+// started past is re-armed once the value is back on the other side.
+// Nothing is marked hit here; sendPositionAlerts does that once the alert
+// is settled, so an alert raised while no bot is connected is retried on a
+// later tick. Must be called with stateMutex held.
+func checkPositions(t Tick) []positionAlert {
+	if !positionAlerts {
+		return nil
+	}
+	var out []positionAlert
+	for user, ps := range positions {
+		for i := range ps {
+			p := &ps[i]
+			if !p.Watched {
+				watchPosition(p, t)
+				markDirty()
+				continue
+			}
+			val := profitValue(t.BuyingRate, t.SellingRate, p.Modal, p.Pokok)
+			if p.AboveTarget && val < p.Target {
+				p.AboveTarget = false
+				markDirty()
+			}
+			if p.BelowStop && val > -p.StopLoss {
+				p.BelowStop = false
+				markDirty()
+			}
+			switch {
+			case p.TargetHit == nil && !p.AboveTarget && val >= p.Target:
+				out = append(out, positionAlert{user, p.ID, false, t.UpdatedAt, p.ChatID, "🎯 <b>Target profit tercapai!</b>\n" + formatPosition(*p, t, true)})
+			case p.StopHit == nil && !p.BelowStop && p.StopLoss > 0 && val <= -p.StopLoss:
+				out = append(out, positionAlert{user, p.ID, true, t.UpdatedAt, p.ChatID, "🛑 <b>Stop-loss tersentuh!</b>\n" + formatPosition(*p, t, true)})
+			}
+		}
+	}
+	return out
+}
+
+func sendPositionAlerts(alerts []positionAlert) {
+	bot := tgBot.Load()
+	if bot == nil || len(alerts) == 0 {
+		return
+	}
+	for _, a := range alerts {
+		chatID, text := a.ChatID, a.Text
+		if positionAlertChat != 0 {
+			chatID, text = positionAlertChat, fmt.Sprintf("🧪 <i>Simulasi, untuk chat %d</i>\n%s", a.ChatID, a.Text)
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "HTML"
+		if _, err := bot.Send(msg); err != nil {
+			if !permanentSendError(err) {
+				slog.Warn("position alert not delivered, will retry", "source", "telegram", "chat_id", chatID, "err", err)
+				continue
+			}
+			slog.Warn("position alert dropped", "source", "telegram", "chat_id", chatID, "err", err)
+		}
+		markPositionHit(a)
+	}
+}
+
+// permanentSendError reports whether Telegram refused a message for a reason
+// a retry cannot fix, such as a blocked bot (403) or a missing chat (400).
+func permanentSendError(err error) bool {
+	var te *tgbotapi.Error
+	if !errors.As(err, &te) {
+		return false
+	}
+	return te.Code == http.StatusBadRequest || te.Code == http.StatusForbidden
+}
+
+func markPositionHit(a positionAlert) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	ps := positions[a.User]
+	for i := range ps {
+		if ps[i].ID != a.ID {
+			continue
+		}
+		at := a.At
+		if a.Stop {
+			ps[i].StopHit = &at
+		} else {
+			ps[i].TargetHit = &at
+		}
+		markDirty()
+	}
+}
+
+// simAlertChat is where position alerts go while prices are not live:
+// SIM_ALERT_CHAT_ID, else ADMIN_CHAT_ID, else 0 when neither is set.
+func simAlertChat() int64 {
+	for _, key := range []string{"SIM_ALERT_CHAT_ID", "ADMIN_CHAT_ID"} {
+		if id, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && id != 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+func formatPosition(p Position, t Tick, ok bool) string {
+	line := fmt.Sprintf("#%d • Modal Rp%s / Pokok Rp%s\n   🎯 +Rp%s", p.ID, formatRupiah(p.Modal), formatRupiah(p.Pokok), formatRupiah(p.Target))
+	if p.StopLoss > 0 {
+		line += fmt.Sprintf(" · 🛑 -Rp%s", formatRupiah(p.StopLoss))
+	}
+	if p.TargetHit != nil {
+		line += " ✅"
+	}
+	if p.StopHit != nil {
+		line += " ⚠️"
+	}
+	if ok {
+		line += "\n   " + calcProfit(t.BuyingRate, t.SellingRate, p.Modal, p.Pokok)
+	}
+	return line
+}
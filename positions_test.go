@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCheckPositionsCrossing(t *testing.T) {
+	// One gram bought at 1.000.000: the value is sell - 1.000.000.
+	// The first tick only records which side the value starts on.
+	tests := []struct {
+		name  string
+		sells []int
+		want  string
+	}{
+		{"crosses target", []int{1000000, 1050000, 1100000, 1200000}, "..T."},
+		{"starts above target", []int{1150000, 1200000, 1120000}, "..."},
+		{"re-armed after dropping back", []int{1150000, 1050000, 1100000}, "..T"},
+		{"crosses stop", []int{1000000, 900000, 850000}, ".S."},
+		{"starts below stop", []int{850000, 800000, 950000, 890000}, "...S"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const user = -1
+			stateMutex.Lock()
+			positions[user] = []Position{{ID: 1, Modal: 1000000, Pokok: 1000000, Target: 100000, StopLoss: 100000}}
+			stateMutex.Unlock()
+			defer RemovePosition(user, 1)
+			got := ""
+			for _, s := range tt.sells {
+				stateMutex.Lock()
+				alerts := checkPositions(Tick{BuyingRate: 1000000, SellingRate: s})
+				stateMutex.Unlock()
+				mark := "."
+				for _, a := range alerts {
+					if a.Stop {
+						mark = "S"
+					} else {
+						mark = "T"
+					}
+					markPositionHit(a)
+				}
+				got += mark
+			}
+			if got != tt.want {
+				t.Errorf("alerts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermanentSendError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, true},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true},
+		{&tgbotapi.Error{Code: 429, Message: "Too Many Requests"}, false},
+		{&tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, false},
+		{errors.New("dial tcp: timeout"), false},
+	}
+	for _, tt := range tests {
+		if got := permanentSendError(tt.err); got != tt.want {
+			t.Errorf("permanentSendError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
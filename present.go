@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// The presentation layer: everything here turns raw ticks into the
+// Indonesian/emoji strings the dashboard and bot show.
+
+func tickStatus(t Tick) string {
+	if t.Diff > 0 {
+		return "🚀"
+	} else if t.Diff < 0 {
+		return "🔻"
+	}
+	return "➖"
+}
+
+func presentTick(t Tick) HistoryItem {
+	status := tickStatus(t)
+	upd := t.UpdatedAt.In(wib()).Format(treasuryTimeLayout)
+	buyFmt := formatRupiah(t.BuyingRate)
+	sellFmt := formatRupiah(t.SellingRate)
+	diffDisplay := formatDiffDisplay(t.Diff, status)
+	return HistoryItem{
+		BuyingRate:         t.BuyingRate,
+		SellingRate:        t.SellingRate,
+		BuyingRateExact:    t.BuyingRateExact,
+		SellingRateExact:   t.SellingRateExact,
+		Status:             status,
+		Diff:               t.Diff,
+		CreatedAt:          upd,
+		WaktuDisplay:       formatWaktuDisplay(upd, status),
+		DiffDisplay:        diffDisplay,
+		TransactionDisplay: formatTransactionDisplay(buyFmt, sellFmt, diffDisplay),
+		Jt20:               calcProfit(t.BuyingRate, t.SellingRate, 20000000, 19314000),
+		Jt30:               calcProfit(t.BuyingRate, t.SellingRate, 30000000, 28980000),
+		Jt40:               calcProfit(t.BuyingRate, t.SellingRate, 40000000, 38652000),
+		Jt50:               calcProfit(t.BuyingRate, t.SellingRate, 50000000, 48325000),
+		XauUsd:             t.XauUsd,
+	}
+}
+
+func formatRupiah(n int) string {
+	s := strconv.Itoa(n)
+	var out []byte
+	cnt := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		out = append([]byte{s[i]}, out...)
+		cnt++
+		if cnt%3 == 0 && i != 0 {
+			out = append([]byte{'.'}, out...)
+		}
+	}
+	return string(out)
+}
+
+// profitValue is the rupiah result of buying with modal at buy and selling
+// at sell, against the pokok actually paid.
+func profitValue(buy, sell, modal, pokok int) int {
+	return int(float64(modal)/float64(buy)*float64(sell)) - pokok
+}
+
+func calcProfit(buy, sell, modal, pokok int) string {
+	val := profitValue(buy, sell, modal, pokok)
+	gramStr := fmt.Sprintf("%.4f", float64(modal)/float64(buy))
+	if val > 0 {
+		return "+" + formatRupiah(val) + "🟢➺" + gramStr + "gr"
+	} else if val < 0 {
+		return "-" + formatRupiah(-val) + "🔴➺" + gramStr + "gr"
+	}
+	return formatRupiah(0) + "➖➺" + gramStr + "gr"
+}
+
+func formatDiffDisplay(diff int, status string) string {
+	if status == "🚀" {
+		return "🚀+" + formatRupiah(diff)
+	} else if status == "🔻" {
+		return "🔻-" + formatRupiah(-diff)
+	}
+	return "➖tetap"
+}
+
+func formatTransactionDisplay(buy, sell, diff string) string {
+	return "Harga Beli: " + buy + " Jual: " + sell + " " + diff
+}
+
+func formatWaktuDisplay(t, status string) string {
+	tm, err := time.Parse("2006-01-02 15:04:05", t)
+	if err != nil {
+		return t + status
+	}
+	hari := []string{"Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu", "Minggu"}
+	wd := tm.Weekday()
+	idx := int(wd)
+	if idx == 0 {
+		idx = 6
+	} else {
+		idx--
+	}
+	return fmt.Sprintf("%s %02d:%02d:%02d %s", hari[idx], tm.Hour(), tm.Minute(), tm.Second(), status)
+}
+
+func formatIndicatorValue(label string, v *float64, rupiah bool) string {
+	if v == nil {
+		return label + " -"
+	}
+	if rupiah {
+		return label + " " + formatRupiah(int(*v+0.5))
+	}
+	return fmt.Sprintf("%s %.2f", label, *v)
+}
+
+func formatIndicators(s IndicatorSnapshot) string {
+	line := fmt.Sprintf("📈 <b>Indikator (%s):</b>\n%s | %s | %s", s.Interval,
+		formatIndicatorValue("RSI", s.RSI, false),
+		formatIndicatorValue("SMA", s.SMA, true),
+		formatIndicatorValue("EMA", s.EMA, true))
+	if s.BollUpper != nil && s.BollLower != nil {
+		line += "\nBollinger: " + formatRupiah(int(*s.BollLower+0.5)) + " – " + formatRupiah(int(*s.BollUpper+0.5))
+	}
+	return line
+}
+
+func formatHarga(t Tick, s IndicatorSnapshot) string {
+	h := presentTick(t)
+	return fmt.Sprintf("💰 <b>Harga Emas Treasury</b>\n━━━━━━━━━━━━━━━━━━━\n🕒 %s\n🟢 <b>Beli:</b> Rp%s\n🔴 <b>Jual:</b> Rp%s\n📊 <b>Perubahan:</b> %s\n\n%s",
+		h.WaktuDisplay, formatRupiah(t.BuyingRate), formatRupiah(t.SellingRate), h.DiffDisplay, formatIndicators(s))
+}
+
+func formatSpread(s SpreadSummary) string {
+	p := s.Latest
+	msg := fmt.Sprintf("↔️ <b>Spread Beli/Jual</b>\n━━━━━━━━━━━━━━━━━━━\n🟢 Beli: Rp%s\n🔴 Jual: Rp%s\n📏 Spread: Rp%s (%.2f%%)\n🎯 Harga jual harus naik %.2f%% (Rp%s) untuk balik modal",
+		formatRupiah(p.BuyingRate), formatRupiah(p.SellingRate), formatRupiah(p.Spread), p.SpreadPct, p.BreakEvenPct, formatRupiah(p.Spread))
+	if d := s.Today; d != nil {
+		msg += fmt.Sprintf("\n\n📅 <b>Hari ini (%d tick):</b>\nMin Rp%s (%.2f%%)\nRata-rata Rp%s (%.2f%%)\nMax Rp%s (%.2f%%)",
+			d.Ticks, formatRupiah(d.Min), d.MinPct, formatRupiah(int(d.Avg+0.5)), d.AvgPct, formatRupiah(d.Max), d.MaxPct)
+	}
+	return msg
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Recording is one upstream response as stored on disk, one JSON object per
+// line in RECORD_DIR/YYYY-MM-DD.jsonl.
+type Recording struct {
+	At     time.Time `json:"at"`
+	Kind   string    `json:"kind"`
+	Symbol string    `json:"symbol,omitempty"`
+	Code   int       `json:"code"`
+	Body   string    `json:"body"`
+}
+
+const (
+	recordTreasury = "treasury"
+	recordQuote    = "quote"
+)
+
+// recordingSource writes a response only when the value it carries differs
+// from the previous one for the same kind and symbol. Bodies cannot be
+// compared directly: Google pages embed per-response tokens, and they are
+// polled several times a second. Day files older than RECORD_RETENTION are
+// deleted whenever a new one is opened.
+type recordingSource struct {
+	inner PriceSource
+	dir   string
+	mu    sync.Mutex
+	day   string
+	f     *os.File
+	last  map[string]string
+}
+
+func newRecordingSource(inner PriceSource, dir string) *recordingSource {
+	return &recordingSource{inner: inner, dir: dir, last: make(map[string]string)}
+}
+
+// recordValue is what a response is deduplicated on: the parsed rate when
+// the body parses, the status code otherwise, so a run of identical
+// failures is recorded once.
+func recordValue(r Recording) string {
+	if r.Code == http.StatusOK {
+		switch r.Kind {
+		case recordTreasury:
+			if t, _, err := decodeTreasury([]byte(r.Body)); err == nil {
+				return t.BuyingRate.String() + "/" + t.SellingRate.String() + "@" + t.UpdatedAt
+			}
+		case recordQuote:
+			if doc, err := goquery.NewDocumentFromReader(strings.NewReader(r.Body)); err == nil {
+				if price, _, _, ok := ExtractQuote(doc, r.Symbol); ok {
+					return price
+				}
+			}
+		}
+	}
+	return "!" + strconv.Itoa(r.Code)
+}
+
+// pruneRecordings deletes day files that ended before the retention window.
+func pruneRecordings(dir string, now time.Time) {
+	cutoff := now.Add(-envDuration("RECORD_RETENTION", 14*24*time.Hour))
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	for _, name := range files {
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSuffix(filepath.Base(name), ".jsonl"), wib())
+		if err != nil || !day.AddDate(0, 0, 1).Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			slog.Warn("removing old recording failed", "file", name, "err", err)
+		}
+	}
+}
+
+// Close flushes and closes the current recording file.
+func (s *recordingSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Sync()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f = nil
+	return err
+}
+
+func (s *recordingSource) FetchTreasury(ctx context.Context) (*SourceResponse, error) {
+	resp, err := s.inner.FetchTreasury(ctx)
+	if err == nil {
+		s.record(Recording{Kind: recordTreasury, Code: resp.Code, Body: string(resp.Body)})
+	}
+	return resp, err
+}
+
+func (s *recordingSource) FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error) {
+	resp, err := s.inner.FetchQuote(ctx, symbol)
+	if err == nil {
+		s.record(Recording{Kind: recordQuote, Symbol: symbol, Code: resp.Code, Body: string(resp.Body)})
+	}
+	return resp, err
+}
+
+func (s *recordingSource) record(r Recording) {
+	r.At = time.Now().In(wib())
+	key := r.Kind + ":" + r.Symbol
+	val := recordValue(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.last[key]; ok && prev == val {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if day := r.At.Format("2006-01-02"); day != s.day || s.f == nil {
+		if s.f != nil {
+			s.f.Close()
+			s.f = nil
+		}
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			slog.Warn("record failed", "dir", s.dir, "err", err)
+			return
+		}
+		pruneRecordings(s.dir, r.At)
+		f, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			slog.Warn("record failed", "dir", s.dir, "err", err)
+			return
+		}
+		s.f, s.day = f, day
+	}
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		slog.Warn("record failed", "file", s.f.Name(), "err", err)
+		return
+	}
+	s.last[key] = val
+}
+
+// ReadRecordings loads a recording file, or every *.jsonl file in a
+// directory, ordered by time.
+func ReadRecordings(path string) ([]Recording, error) {
+	files := []string{path}
+	if fi, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if fi.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*.jsonl"))
+	}
+	var out []Recording
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 16<<20)
+		for line := 1; sc.Scan(); line++ {
+			if strings.TrimSpace(sc.Text()) == "" {
+				continue
+			}
+			var r Recording
+			if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+			}
+			out = append(out, r)
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+var errReplayPending = errors.New("replay: no recording yet for this source")
+
+// replaySource plays recordings back on a virtual clock that starts at the
+// first recording and runs speed times faster than the wall clock; speed 0
+// hands out every recording as fast as it is polled. Each call returns the
+// next unseen recording that is due, so no response is skipped when the
+// pollers keep up, and repeats the last one otherwise.
+type replaySource struct {
+	mu     sync.Mutex
+	speed  float64
+	origin time.Time
+	start  time.Time
+	queues map[string][]Recording
+	last   map[string]*Recording
+}
+
+func newReplaySource(path string, speed float64) (*replaySource, error) {
+	if path == "" {
+		return nil, errors.New("REPLAY_PATH is required for PRICE_SOURCE=replay")
+	}
+	recs, err := ReadRecordings(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("no recordings in %s", path)
+	}
+	s := &replaySource{
+		speed:  speed,
+		origin: recs[0].At,
+		start:  time.Now(),
+		queues: make(map[string][]Recording),
+		last:   make(map[string]*Recording),
+	}
+	for _, r := range recs {
+		k := r.Kind + ":" + r.Symbol
+		s.queues[k] = append(s.queues[k], r)
+	}
+	slog.Info("replaying recordings", "path", path, "count", len(recs), "from", recs[0].At, "to", recs[len(recs)-1].At, "speed", speed)
+	return s, nil
+}
+
+func (s *replaySource) now() time.Time {
+	return s.origin.Add(time.Duration(float64(time.Since(s.start)) * s.speed))
+}
+
+func (s *replaySource) next(key string) (*SourceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queues[key]
+	if len(q) > 0 && (s.speed == 0 || !q[0].At.After(s.now())) {
+		r := q[0]
+		s.queues[key] = q[1:]
+		s.last[key] = &r
+	}
+	r := s.last[key]
+	if r == nil {
+		return nil, &fetchError{Reason: "network", Err: errReplayPending}
+	}
+	return &SourceResponse{Code: r.Code, Body: []byte(r.Body), At: r.At}, nil
+}
+
+// Done reports whether every recording has been handed out.
+func (s *replaySource) Done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.queues {
+		if len(q) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *replaySource) FetchTreasury(ctx context.Context) (*SourceResponse, error) {
+	return s.next(recordTreasury + ":")
+}
+
+func (s *replaySource) FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error) {
+	return s.next(recordQuote + ":" + symbol)
+}
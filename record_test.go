@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedSource struct{ body string }
+
+func (s *fixedSource) FetchTreasury(ctx context.Context) (*SourceResponse, error) {
+	return &SourceResponse{Code: 200, Body: []byte(s.body)}, nil
+}
+
+func (s *fixedSource) FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error) {
+	return &SourceResponse{Code: 200, Body: []byte(s.body)}, nil
+}
+
+func TestRecordingSourceDedupe(t *testing.T) {
+	dir := t.TempDir()
+	page := func(token, price string) string {
+		return `<html><script>var t="` + token + `"</script><div data-last-price="` + price + `"></div></html>`
+	}
+	tests := []struct {
+		body   string
+		stored bool
+	}{
+		{page("a1", "16234.5"), true},
+		{page("b2", "16234.5"), false},
+		{page("c3", "16240"), true},
+		{"<html>captcha</html>", true},
+		{"<html>captcha again</html>", false},
+		{page("d4", "16240"), true},
+	}
+	src := &fixedSource{}
+	rec := newRecordingSource(src, dir)
+	want := 0
+	for _, tt := range tests {
+		src.body = tt.body
+		rec.FetchQuote(context.Background(), "USD-IDR")
+		if tt.stored {
+			want++
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := ReadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != want {
+		t.Errorf("stored %d recordings, want %d", len(recs), want)
+	}
+}
+
+func TestPruneRecordings(t *testing.T) {
+	t.Setenv("RECORD_RETENTION", "48h")
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, wib())
+	for _, name := range []string{"2026-03-06.jsonl", "2026-03-07.jsonl", "2026-03-08.jsonl", "2026-03-10.jsonl", "notes.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneRecordings(dir, now)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.Base(f))
+	}
+	if want := "2026-03-08.jsonl 2026-03-10.jsonl notes.jsonl"; strings.Join(got, " ") != want {
+		t.Errorf("kept %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Announcement keeps both the admin's original text (Source), which is
+// what the bot echoes back, and the sanitized dashboard HTML (Text), which
+// uses tags Telegram cannot parse.
+type Announcement struct {
+	ID     int       `json:"id"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Text   string    `json:"text"`
+	Source string    `json:"source"`
+}
+
+var (
+	treasuryBase   = "Belum ada info treasury."
+	announcements  []Announcement
+	nextAnnounceID = 1
+)
+
+func wib() *time.Location {
+	return time.FixedZone("WIB", 7*3600)
+}
+
+// parseJadwalTime accepts "HH:MM" (today, WIB) or "YYYY-MM-DDTHH:MM" / "YYYY-MM-DD_HH:MM".
+func parseJadwalTime(s string, now time.Time) (time.Time, bool, error) {
+	s = strings.ReplaceAll(s, ".", ":")
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02_15:04"} {
+		if t, err := time.ParseInLocation(layout, s, wib()); err == nil {
+			return t, true, nil
+		}
+	}
+	t, err := time.ParseInLocation("15:04", s, wib())
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, wib()), false, nil
+}
+
+// ParseJadwal splits "/jadwal <start> <end> <text>" arguments into a window.
+// Clock-only times roll forward so "22:00 02:00" spans midnight and a window
+// that already ended today is scheduled for tomorrow.
+func ParseJadwal(args string, now time.Time) (time.Time, time.Time, string, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 3 {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("argumen kurang")
+	}
+	start, startDated, err := parseJadwalTime(fields[0], now)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("waktu mulai tidak valid")
+	}
+	end, endDated, err := parseJadwalTime(fields[1], now)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("waktu selesai tidak valid")
+	}
+	if !endDated && !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	if !startDated && !endDated && !end.After(now) {
+		start = start.AddDate(0, 0, 1)
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("waktu selesai harus setelah waktu mulai")
+	}
+	if !end.After(now) {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("jadwal sudah lewat")
+	}
+	idx := strings.Index(args, fields[0]) + len(fields[0])
+	idx += strings.Index(args[idx:], fields[1]) + len(fields[1])
+	return start, end, strings.TrimLeft(args[idx:], " \t\n"), nil
+}
+
+func AddAnnouncement(start, end time.Time, text, source string) Announcement {
+	stateMutex.Lock()
+	a := Announcement{ID: nextAnnounceID, Start: start, End: end, Text: text, Source: source}
+	nextAnnounceID++
+	announcements = append(announcements, a)
+	sort.Slice(announcements, func(i, j int) bool { return announcements[i].Start.Before(announcements[j].Start) })
+	markDirty()
+	stateMutex.Unlock()
+	RefreshTreasuryInfo()
+	return a
+}
+
+func RemoveAnnouncement(id int) bool {
+	stateMutex.Lock()
+	found := false
+	for i, a := range announcements {
+		if a.ID == id {
+			announcements = append(announcements[:i], announcements[i+1:]...)
+			markDirty()
+			found = true
+			break
+		}
+	}
+	stateMutex.Unlock()
+	if found {
+		RefreshTreasuryInfo()
+	}
+	return found
+}
+
+func ListAnnouncements() []Announcement {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	return append([]Announcement(nil), announcements...)
+}
+
+func SetTreasuryBase(info string) {
+	stateMutex.Lock()
+	treasuryBase = info
+	stateMutex.Unlock()
+	RefreshTreasuryInfo()
+}
+
+// RefreshTreasuryInfo drops expired announcements, merges the active ones
+// into TreasuryInfo and broadcasts only when the result changed.
+func RefreshTreasuryInfo() {
+	now := time.Now()
+	stateMutex.Lock()
+	parts := []string{treasuryBase}
+	kept := announcements[:0]
+	for _, a := range announcements {
+		if !a.End.After(now) {
+			continue
+		}
+		kept = append(kept, a)
+		if !a.Start.After(now) {
+			parts = append(parts, "📢 "+a.Text)
+		}
+	}
+	if len(kept) != len(announcements) {
+		markDirty()
+	}
+	announcements = kept
+	info := strings.Join(parts, "<br><br>")
+	changed := info != state.TreasuryInfo
+	state.TreasuryInfo = info
+	stateMutex.Unlock()
+	if changed {
+		BroadcastState()
+	}
+}
+
+func StartAnnouncementScheduler(ctx context.Context) {
+	for {
+		RefreshTreasuryInfo()
+		if !sleepCtx(ctx, 15*time.Second) {
+			return
+		}
+	}
+}
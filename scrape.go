@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type FxHealth struct {
+	OK           bool   `json:"ok"`
+	Strategy     string `json:"strategy"`
+	FailingSince string `json:"failing_since,omitempty"`
+}
+
+type fxScrapeHealth struct {
+	failingSince time.Time
+	alerted      bool
+}
+
+var defaultQuoteSelectors = []string{"div.YMlKec.fxKbKc", "div.YMlKec", "div[jsname=ip75Cb] div", "span[jsname=vWLAgc]"}
+
+// plausibleFxRanges bounds each pair so a selector that lands on the wrong
+// number (a percentage, a chart label) is not accepted as a price.
+var plausibleFxRanges = map[string][2]float64{
+	"USD-IDR": {10000, 25000},
+	"SGD-IDR": {8000, 20000},
+	"EUR-IDR": {12000, 28000},
+	"MYR-IDR": {2500, 6000},
+	"XAU-USD": {1000, 10000},
+}
+
+func quoteSelectors() []string {
+	raw := os.Getenv("FX_SELECTORS")
+	if raw == "" {
+		return defaultQuoteSelectors
+	}
+	var out []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return append(out, defaultQuoteSelectors...)
+}
+
+// fxRange reads FX_RANGE_USD_IDR="min:max" style overrides before falling
+// back to the built-in table.
+func fxRange(pair string) (float64, float64, bool) {
+	if raw := os.Getenv("FX_RANGE_" + strings.ReplaceAll(pair, "-", "_")); raw != "" {
+		parts := strings.SplitN(raw, ":", 2)
+		if len(parts) == 2 {
+			lo, err1 := strconv.ParseFloat(parts[0], 64)
+			hi, err2 := strconv.ParseFloat(parts[1], 64)
+			if err1 == nil && err2 == nil && lo < hi {
+				return lo, hi, true
+			}
+		}
+	}
+	r, ok := plausibleFxRanges[pair]
+	return r[0], r[1], ok
+}
+
+func plausibleRate(pair string, rate float64) bool {
+	lo, hi, ok := fxRange(pair)
+	if !ok {
+		return rate > 0
+	}
+	return rate >= lo && rate <= hi
+}
+
+// ExtractQuote tries each strategy in turn: known CSS selectors, the
+// data-last-price attribute and JSON-LD offers. The first value inside the
+// plausible range for pair wins.
+func ExtractQuote(doc *goquery.Document, pair string) (string, float64, string, bool) {
+	for _, sel := range quoteSelectors() {
+		var price string
+		var rate float64
+		doc.Find(sel).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			text := strings.TrimSpace(s.Text())
+			if v, ok := parseRate(text); ok && plausibleRate(pair, v) {
+				price, rate = text, v
+				return false
+			}
+			return true
+		})
+		if price != "" {
+			return price, rate, "selector:" + sel, true
+		}
+	}
+	var price string
+	var rate float64
+	doc.Find("[data-last-price]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		attr, _ := s.Attr("data-last-price")
+		v, err := strconv.ParseFloat(strings.TrimSpace(attr), 64)
+		if err == nil && plausibleRate(pair, v) {
+			price, rate = formatQuote(v), v
+			return false
+		}
+		return true
+	})
+	if price != "" {
+		return price, rate, "data-last-price", true
+	}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var v interface{}
+		if json.Unmarshal([]byte(s.Text()), &v) != nil {
+			return true
+		}
+		for _, c := range jsonLDPrices(v) {
+			if plausibleRate(pair, c) {
+				price, rate = formatQuote(c), c
+				return false
+			}
+		}
+		return true
+	})
+	if price != "" {
+		return price, rate, "json-ld", true
+	}
+	return "", 0, "", false
+}
+
+func jsonLDPrices(v interface{}) []float64 {
+	var out []float64
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if k == "price" || k == "lowPrice" || k == "highPrice" {
+				switch p := val.(type) {
+				case float64:
+					out = append(out, p)
+				case string:
+					if f, ok := parseRate(p); ok {
+						out = append(out, f)
+					}
+				}
+				continue
+			}
+			out = append(out, jsonLDPrices(val)...)
+		}
+	case []interface{}:
+		for _, val := range t {
+			out = append(out, jsonLDPrices(val)...)
+		}
+	}
+	return out
+}
+
+func formatQuote(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	intPart, frac := s[:len(s)-3], s[len(s)-2:]
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String() + "." + frac
+}
+
+func fxAlertAfter() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("FX_ALERT_MINUTES")); err == nil && n > 0 {
+		return time.Duration(n) * time.Minute
+	}
+	return 5 * time.Minute
+}
+
+// recordScrape updates the per-pair health flag. A pair is marked unhealthy,
+// and the admin alerted once, only after every strategy has failed for
+// FX_ALERT_MINUTES; the first success afterwards clears it.
+func recordScrape(pair, strategy string, ok bool) {
+	now := time.Now()
+	var alert string
+	changed := false
+	stateMutex.Lock()
+	h := fxHealth[pair]
+	if h == nil {
+		h = &fxScrapeHealth{}
+		fxHealth[pair] = h
+	}
+	cur := state.FxHealth[pair]
+	if ok {
+		if h.alerted {
+			alert = fmt.Sprintf("✅ <b>Scraper pulih</b>\nPair: %s\nStrategi: %s", pair, strategy)
+		}
+		h.failingSince = time.Time{}
+		h.alerted = false
+		next := FxHealth{OK: true, Strategy: strategy}
+		changed = next != cur
+		state.FxHealth[pair] = next
+	} else {
+		if h.failingSince.IsZero() {
+			h.failingSince = now
+		}
+		if !h.alerted && now.Sub(h.failingSince) >= fxAlertAfter() {
+			h.alerted = true
+			alert = fmt.Sprintf("⚠️ <b>Scraper Google Finance gagal</b>\nPair: %s\nSemua strategi gagal sejak %s WIB", pair, h.failingSince.In(wib()).Format("15:04:05"))
+			state.FxHealth[pair] = FxHealth{OK: false, Strategy: cur.Strategy, FailingSince: h.failingSince.Format(time.RFC3339)}
+			changed = true
+		}
+	}
+	stateMutex.Unlock()
+	if changed {
+		BroadcastState()
+	}
+	if alert != "" {
+		if ok {
+			slog.Info("scraper recovered", "source", "google:"+pair, "strategy", strategy)
+		} else {
+			slog.Error("scraper broken: all strategies failing", "source", "google:"+pair, "since", h.failingSince)
+		}
+		NotifyAdmin(alert)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simSource is a random-walk PriceSource for frontend work and load tests.
+// Prices advance lazily in SIM_TICK_INTERVAL steps whenever they are
+// polled; with probability SIM_GAP_PROB a step starts a SIM_GAP_DURATION
+// pause without changes so stale-feed handling can be exercised. The gap
+// defaults to two minutes past FEED_STALE_AFTER so it does go stale.
+type simSource struct {
+	mu        sync.Mutex
+	rng       *rand.Rand
+	interval  time.Duration
+	volPct    float64
+	fxVolPct  float64
+	gapProb   float64
+	gapFor    time.Duration
+	spreadPct float64
+	gold      *simWalk
+	quotes    map[string]*simWalk
+}
+
+// simWalk steps at at; changedAt is when price last moved and is what the
+// synthetic Treasury response reports as updated_at.
+type simWalk struct {
+	price     float64
+	vol       float64
+	round     float64
+	at        time.Time
+	changedAt time.Time
+	gapUntil  time.Time
+}
+
+// simQuoteBase seeds known Google Finance symbols near realistic levels.
+var simQuoteBase = map[string]float64{
+	"USD-IDR": 16300,
+	"SGD-IDR": 12600,
+	"EUR-IDR": 17700,
+	"MYR-IDR": 3850,
+	"XAU-USD": 2650,
+}
+
+func newSimSource() *simSource {
+	seed := envInt("SIM_SEED", time.Now().UnixNano())
+	interval := envDuration("SIM_TICK_INTERVAL", 5*time.Second)
+	if interval < time.Second {
+		// Treasury timestamps have one-second resolution, so faster ticks
+		// would be dropped as duplicates.
+		interval = time.Second
+	}
+	s := &simSource{
+		rng:       rand.New(rand.NewSource(seed)),
+		interval:  interval,
+		volPct:    envFloat("SIM_VOLATILITY", 0.05),
+		fxVolPct:  envFloat("SIM_FX_VOLATILITY", 0.01),
+		gapProb:   envFloat("SIM_GAP_PROB", 0.002),
+		gapFor:    envDuration("SIM_GAP_DURATION", feedStaleAfter()+2*time.Minute),
+		spreadPct: envFloat("SIM_SPREAD", 2.5),
+		quotes:    make(map[string]*simWalk),
+	}
+	now := time.Now().Truncate(time.Second)
+	s.gold = &simWalk{price: float64(envInt("SIM_START_PRICE", 1500000)), vol: s.volPct, round: 1, at: now, changedAt: now}
+	return s
+}
+
+// advance moves w forward one step per elapsed interval and reports whether
+// the price changed.
+func (s *simSource) advance(w *simWalk, now time.Time) bool {
+	changed := false
+	for next := w.at.Add(s.interval); !next.After(now); next = w.at.Add(s.interval) {
+		w.at = next
+		if next.Before(w.gapUntil) {
+			continue
+		}
+		if s.rng.Float64() < s.gapProb {
+			w.gapUntil = next.Add(s.gapFor)
+			continue
+		}
+		step := w.price * w.vol / 100 * s.rng.NormFloat64()
+		price := math.Max(w.round, math.Round((w.price+step)/w.round)*w.round)
+		if price != w.price {
+			w.price, w.changedAt = price, next
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *simSource) FetchTreasury(ctx context.Context) (*SourceResponse, error) {
+	s.mu.Lock()
+	s.advance(s.gold, time.Now())
+	buy := s.gold.price
+	sell := math.Round(buy * (1 - s.spreadPct/100))
+	at := s.gold.changedAt
+	s.mu.Unlock()
+	body, _ := json.Marshal(map[string]interface{}{
+		"data": map[string]string{
+			"buying_rate":  strconv.FormatFloat(buy, 'f', -1, 64),
+			"selling_rate": strconv.FormatFloat(sell, 'f', -1, 64),
+			"updated_at":   at.In(wib()).Format(treasuryTimeLayout),
+		},
+	})
+	return &SourceResponse{Code: 200, Body: body}, nil
+}
+
+func (s *simSource) FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error) {
+	s.mu.Lock()
+	w := s.quotes[symbol]
+	if w == nil {
+		base, ok := simQuoteBase[strings.ToUpper(symbol)]
+		if !ok {
+			base = 100
+		}
+		w = &simWalk{price: base, vol: s.fxVolPct, round: 0.01, at: time.Now().Truncate(time.Second)}
+		s.quotes[symbol] = w
+	}
+	s.advance(w, time.Now())
+	price := w.price
+	s.mu.Unlock()
+	body := fmt.Sprintf(`<html><body><div class="YMlKec fxKbKc" data-last-price="%.2f">%s</div></body></html>`, price, strconv.FormatFloat(price, 'f', 2, 64))
+	return &SourceResponse{Code: 200, Body: []byte(body)}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// PriceSource supplies raw upstream responses. Everything after the fetch
+// (decoding, validation, de-duplication, broadcasts and alerts) is shared,
+// so replayed or synthetic data takes the same path as live data.
+type PriceSource interface {
+	FetchTreasury(ctx context.Context) (*SourceResponse, error)
+	FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error)
+}
+
+// SourceResponse is one upstream reply. At is set only by sources that
+// replay past data; live responses leave it zero.
+type SourceResponse struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+	At     time.Time
+}
+
+// fetchError keeps the metric reason and status of a failure that happened
+// before a complete body was available.
+type fetchError struct {
+	Code   int
+	Reason string
+	Err    error
+}
+
+func (e *fetchError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.Err
+}
+
+func failureOf(err error) (int, string) {
+	if fe, ok := err.(*fetchError); ok {
+		return fe.Code, fe.Reason
+	}
+	return 0, "network"
+}
+
+var priceSource PriceSource = liveSource{}
+
+// InitPriceSource selects the source by mode (live, sim or replay); the CLI
+// defaults it to PRICE_SOURCE. With RECORD_DIR set, live responses are also
+// written to disk.
+func InitPriceSource(mode string) error {
+	switch mode = strings.ToLower(mode); mode {
+	case "", "live":
+		priceSource = liveSource{}
+		if dir := os.Getenv("RECORD_DIR"); dir != "" {
+			priceSource = newRecordingSource(priceSource, dir)
+			slog.Info("recording upstream responses", "dir", dir)
+		}
+	case "replay":
+		src, err := newReplaySource(os.Getenv("REPLAY_PATH"), envFloat("REPLAY_SPEED", 1))
+		if err != nil {
+			return err
+		}
+		priceSource = src
+	case "sim":
+		priceSource = newSimSource()
+		slog.Warn("using simulated prices, not connected to Treasury or Google")
+	default:
+		return fmt.Errorf("unknown PRICE_SOURCE %q", mode)
+	}
+	return nil
+}
+
+// sourceIsLive reports whether prices come from the real upstreams, which
+// follow MARKET_HOURS. Simulated and replayed feeds ignore the schedule.
+func sourceIsLive() bool {
+	switch priceSource.(type) {
+	case liveSource, *recordingSource:
+		return true
+	}
+	return false
+}
+
+type liveSource struct{}
+
+func (liveSource) FetchTreasury(ctx context.Context) (*SourceResponse, error) {
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.treasury.id/api/v1/antigrvty/gold/rate", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://treasury.id")
+	req.Header.Set("Referer", "https://treasury.id/")
+	return doLive(treasuryClient, req, envInt("TREASURY_MAX_BYTES", 256<<10))
+}
+
+func (liveSource) FetchQuote(ctx context.Context, symbol string) (*SourceResponse, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://www.google.com/finance/quote/"+symbol, nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.AddCookie(&http.Cookie{Name: "CONSENT", Value: "YES+cb.20231208-04-p0.en+FX+410"})
+	return doLive(googleClient, req, envInt("FX_MAX_BYTES", 4<<20))
+}
+
+func doLive(c *http.Client, req *http.Request, limit int64) (*SourceResponse, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, &fetchError{Reason: "network", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, limit)
+	if err != nil {
+		return nil, &fetchError{Code: resp.StatusCode, Reason: "read", Err: err}
+	}
+	return &SourceResponse{Code: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
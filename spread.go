@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SpreadPoint struct {
+	Time         time.Time `json:"time"`
+	BuyingRate   int       `json:"buying_rate"`
+	SellingRate  int       `json:"selling_rate"`
+	Spread       int       `json:"spread"`
+	SpreadPct    float64   `json:"spread_pct"`
+	BreakEvenPct float64   `json:"break_even_pct"`
+}
+
+type DailySpread struct {
+	Date   string  `json:"date"`
+	Ticks  int     `json:"ticks"`
+	Min    int     `json:"min"`
+	Avg    float64 `json:"avg"`
+	Max    int     `json:"max"`
+	MinPct float64 `json:"min_pct"`
+	AvgPct float64 `json:"avg_pct"`
+	MaxPct float64 `json:"max_pct"`
+}
+
+type SpreadSummary struct {
+	Latest *SpreadPoint `json:"latest,omitempty"`
+	Today  *DailySpread `json:"today,omitempty"`
+}
+
+// spreadOf describes the cost of a round trip at one tick. Break-even is
+// how far the selling rate must rise before a lot bought now can be sold
+// without loss.
+func spreadOf(t Tick) SpreadPoint {
+	p := SpreadPoint{
+		Time:        t.UpdatedAt,
+		BuyingRate:  t.BuyingRate,
+		SellingRate: t.SellingRate,
+		Spread:      t.BuyingRate - t.SellingRate,
+	}
+	if t.BuyingRate > 0 {
+		p.SpreadPct = round4(float64(p.Spread) / float64(t.BuyingRate) * 100)
+	}
+	if t.SellingRate > 0 {
+		p.BreakEvenPct = round4(float64(p.Spread) / float64(t.SellingRate) * 100)
+	}
+	return p
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// DailySpreads aggregates spreads per WIB calendar day, oldest first.
+func DailySpreads(ts []Tick) []DailySpread {
+	var out []DailySpread
+	var sum, sumPct float64
+	for _, t := range ts {
+		p := spreadOf(t)
+		day := t.UpdatedAt.In(wib()).Format("2006-01-02")
+		if n := len(out); n == 0 || out[n-1].Date != day {
+			if n > 0 {
+				out[n-1].Avg = round2(sum / float64(out[n-1].Ticks))
+				out[n-1].AvgPct = round4(sumPct / float64(out[n-1].Ticks))
+			}
+			out = append(out, DailySpread{Date: day, Min: p.Spread, Max: p.Spread, MinPct: p.SpreadPct, MaxPct: p.SpreadPct})
+			sum, sumPct = 0, 0
+		}
+		d := &out[len(out)-1]
+		d.Ticks++
+		sum += float64(p.Spread)
+		sumPct += p.SpreadPct
+		if p.Spread < d.Min {
+			d.Min = p.Spread
+		}
+		if p.Spread > d.Max {
+			d.Max = p.Spread
+		}
+		d.MinPct = math.Min(d.MinPct, p.SpreadPct)
+		d.MaxPct = math.Max(d.MaxPct, p.SpreadPct)
+	}
+	if n := len(out); n > 0 {
+		out[n-1].Avg = round2(sum / float64(out[n-1].Ticks))
+		out[n-1].AvgPct = round4(sumPct / float64(out[n-1].Ticks))
+	}
+	return out
+}
+
+// updateSpread must be called with stateMutex held.
+func updateSpread() {
+	last, ok := lastTick()
+	if !ok {
+		state.Spread = SpreadSummary{}
+		return
+	}
+	p := spreadOf(last)
+	s := SpreadSummary{Latest: &p}
+	today := last.UpdatedAt.In(wib()).Format("2006-01-02")
+	i := len(ticks)
+	for i > 0 && ticks[i-1].UpdatedAt.In(wib()).Format("2006-01-02") == today {
+		i--
+	}
+	if days := DailySpreads(ticks[i:]); len(days) > 0 {
+		s.Today = &days[0]
+	}
+	state.Spread = s
+}
+
+func SpreadHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if n, err := strconv.Atoi(r.URL.Query().Get("points")); err == nil && n >= 0 {
+		limit = n
+	}
+	stateMutex.RLock()
+	ts := ticks
+	summary := state.Spread
+	daily := DailySpreads(ts)
+	if len(ts) > limit {
+		ts = ts[len(ts)-limit:]
+	}
+	points := make([]SpreadPoint, len(ts))
+	for i, t := range ts {
+		points[i] = spreadOf(t)
+	}
+	stateMutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"latest": summary.Latest,
+		"daily":  daily,
+		"points": points,
+	})
+}
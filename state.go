@@ -1,50 +1,69 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/websocket"
 )
 
-type HistoryItem struct {
-	BuyingRate         int    `json:"buying_rate"`
-	SellingRate        int    `json:"selling_rate"`
-	Status             string `json:"status"`
-	Diff               int    `json:"diff"`
-	CreatedAt          string `json:"created_at"`
-	WaktuDisplay       string `json:"waktu_display"`
-	DiffDisplay        string `json:"diff_display"`
-	TransactionDisplay string `json:"transaction_display"`
-	Jt20               string `json:"jt20"`
-	Jt30               string `json:"jt30"`
-	Jt40               string `json:"jt40"`
-	Jt50               string `json:"jt50"`
+// Tick is one Treasury rate as received, without any display formatting.
+type Tick struct {
+	BuyingRate       int       `json:"buying_rate"`
+	SellingRate      int       `json:"selling_rate"`
+	BuyingRateExact  string    `json:"buying_rate_exact"`
+	SellingRateExact string    `json:"selling_rate_exact"`
+	Diff             int       `json:"diff"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	XauUsd           float64   `json:"xau_usd,omitempty"`
 }
 
-type UsdIdrItem struct {
-	Price string `json:"price"`
-	Time  string `json:"time"`
+// HistoryItem is the dashboard row derived from a Tick by presentTick.
+type HistoryItem struct {
+	BuyingRate         int     `json:"buying_rate"`
+	SellingRate        int     `json:"selling_rate"`
+	BuyingRateExact    string  `json:"buying_rate_exact"`
+	SellingRateExact   string  `json:"selling_rate_exact"`
+	Status             string  `json:"status"`
+	Diff               int     `json:"diff"`
+	CreatedAt          string  `json:"created_at"`
+	WaktuDisplay       string  `json:"waktu_display"`
+	DiffDisplay        string  `json:"diff_display"`
+	TransactionDisplay string  `json:"transaction_display"`
+	Jt20               string  `json:"jt20"`
+	Jt30               string  `json:"jt30"`
+	Jt40               string  `json:"jt40"`
+	Jt50               string  `json:"jt50"`
+	XauUsd             float64 `json:"xau_usd"`
 }
 
+// TransferJam is the last transfer time reported with /in. In and At keep
+// the raw times behind the display strings.
 type TransferJam struct {
-	JamMasuk   string `json:"jam_masuk"`
-	Durasi     string `json:"durasi"`
-	LastUpdate string `json:"last_update"`
+	JamMasuk   string    `json:"jam_masuk"`
+	Durasi     string    `json:"durasi"`
+	LastUpdate string    `json:"last_update"`
+	In         time.Time `json:"-"`
+	At         time.Time `json:"-"`
 }
 
 type State struct {
-	History       []HistoryItem `json:"history"`
-	UsdIdrHistory []UsdIdrItem  `json:"usd_idr_history"`
-	TreasuryInfo  string        `json:"treasury_info"`
-	TransferJam   TransferJam   `json:"transfer_jam"`
+	History       []HistoryItem         `json:"history"`
+	UsdIdrHistory []FxItem              `json:"usd_idr_history"`
+	FxHistory     map[string][]FxItem   `json:"fx_history"`
+	FxHealth      map[string]FxHealth   `json:"fx_health"`
+	Xau           XauInfo               `json:"xau"`
+	FeedStatus    map[string]FeedStatus `json:"feed_status"`
+	Indicators    IndicatorSnapshot     `json:"indicators"`
+	Spread        SpreadSummary         `json:"spread"`
+	TreasuryInfo  string                `json:"treasury_info"`
+	TransferJam   TransferJam           `json:"transfer_jam"`
 }
 
 var (
@@ -52,14 +71,69 @@ var (
 	stateMutex sync.RWMutex
 	wsClients  = make(map[*WsConn]bool)
 	wsMutex    sync.Mutex
-	lastBuy    int
-	shownUpd   = make(map[string]bool)
 	banned     = make(map[int64]bool)
+	ticks      []Tick
 )
 
+// maxHistory is the dashboard window. ticks itself keeps TICK_RETENTION
+// worth of data for stats, indicators and backtests.
+const maxHistory = 1441
+
+func tickRetention() time.Duration {
+	return envDuration("TICK_RETENTION", 35*24*time.Hour)
+}
+
+// pruneTicks drops ticks older than the retention window ending at now.
+func pruneTicks(ts []Tick, now time.Time) []Tick {
+	cutoff := now.Add(-tickRetention())
+	i := sort.Search(len(ts), func(i int) bool { return !ts[i].UpdatedAt.Before(cutoff) })
+	return ts[i:]
+}
+
+// appendTick stores t and its presentation row. Retention is measured from
+// t rather than the wall clock so replays of old recordings keep their data.
+// Must be called with stateMutex held.
+func appendTick(t Tick) {
+	ticks = pruneTicks(append(ticks, t), t.UpdatedAt)
+	state.History = append(state.History, presentTick(t))
+	if len(state.History) > maxHistory {
+		state.History = state.History[len(state.History)-maxHistory:]
+	}
+}
+
+// setTicks replaces the stored ticks and rebuilds the presentation rows.
+// Must be called with stateMutex held.
+func setTicks(ts []Tick) {
+	ticks = pruneTicks(ts, time.Now())
+	window := dashboardTicks()
+	state.History = make([]HistoryItem, len(window))
+	for i, t := range window {
+		state.History[i] = presentTick(t)
+	}
+}
+
+// dashboardTicks is the tail of ticks shown as history rows. Must be called
+// with stateMutex held.
+func dashboardTicks() []Tick {
+	if len(ticks) > maxHistory {
+		return ticks[len(ticks)-maxHistory:]
+	}
+	return ticks
+}
+
+func lastTick() (Tick, bool) {
+	if len(ticks) == 0 {
+		return Tick{}, false
+	}
+	return ticks[len(ticks)-1], true
+}
+
 func InitState() {
 	state = State{
 		TreasuryInfo: "Belum ada info treasury.",
+		FxHistory:    make(map[string][]FxItem),
+		FxHealth:     make(map[string]FxHealth),
+		FeedStatus:   make(map[string]FeedStatus),
 		TransferJam:  TransferJam{},
 	}
 }
@@ -71,36 +145,106 @@ func GetStateBytes() []byte {
 	return b
 }
 
+// RawState is the ?view=raw payload for integrations: numbers, identifiers
+// and RFC 3339 times only, none of the display strings in State.
+type RawState struct {
+	Ticks        []Tick                `json:"ticks"`
+	Fx           map[string][]FxQuote  `json:"fx"`
+	FxHealth     map[string]FxHealth   `json:"fx_health"`
+	Xau          *XauInfo              `json:"xau,omitempty"`
+	FeedStatus   map[string]FeedStatus `json:"feed_status"`
+	Indicators   IndicatorSnapshot     `json:"indicators"`
+	Spread       SpreadSummary         `json:"spread"`
+	TreasuryInfo string                `json:"treasury_info"`
+	Transfer     *RawTransfer          `json:"transfer,omitempty"`
+}
+
+type RawTransfer struct {
+	In              time.Time `json:"in"`
+	DurationMinutes int       `json:"duration_minutes"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// GetRawStateBytes encodes the RawState matching the current dashboard.
+func GetRawStateBytes() []byte {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	raw := RawState{
+		Ticks:        dashboardTicks(),
+		Fx:           make(map[string][]FxQuote, len(fxLog)),
+		FxHealth:     state.FxHealth,
+		FeedStatus:   state.FeedStatus,
+		Indicators:   state.Indicators,
+		Spread:       state.Spread,
+		TreasuryInfo: htmlToText(state.TreasuryInfo),
+	}
+	for pair, h := range fxLog {
+		if len(h) > fxDisplayMax {
+			h = h[len(h)-fxDisplayMax:]
+		}
+		raw.Fx[pair] = h
+	}
+	if state.Xau.Implied > 0 {
+		x := state.Xau
+		raw.Xau = &x
+	}
+	if tj := state.TransferJam; !tj.In.IsZero() {
+		raw.Transfer = &RawTransfer{In: tj.In, DurationMinutes: int(tj.At.Sub(tj.In).Minutes()), UpdatedAt: tj.At}
+	}
+	b, _ := json.Marshal(raw)
+	return b
+}
+
+func isRawView(r *http.Request) bool {
+	return r.URL.Query().Get("view") == "raw"
+}
+
 func ApiStateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if isRawView(r) {
+		w.Write(GetRawStateBytes())
+		return
+	}
 	w.Write(GetStateBytes())
 }
 
 type WsConn struct {
 	Conn *websocket.Conn
 	Send chan []byte
+	Raw  bool
 }
 
 func WsHandler(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		slog.Warn("websocket upgrade failed", "remote", r.RemoteAddr, "err", err)
 		return
 	}
-	ws := &WsConn{Conn: conn, Send: make(chan []byte, 8)}
+	ws := &WsConn{Conn: conn, Send: make(chan []byte, 8), Raw: isRawView(r)}
 	wsMutex.Lock()
 	if len(wsClients) >= 500 {
 		wsMutex.Unlock()
+		mWsRejected.Inc()
+		slog.Warn("websocket rejected: too many connections", "remote", r.RemoteAddr)
 		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1013, "Too many connections"))
 		conn.Close()
 		return
 	}
 	wsClients[ws] = true
+	mWsConnects.Inc()
+	mWsConnections.Set(float64(len(wsClients)))
 	wsMutex.Unlock()
-	ws.Send <- GetStateBytes()
+	if ws.Raw {
+		ws.Send <- GetRawStateBytes()
+	} else {
+		ws.Send <- GetStateBytes()
+	}
 	go func() {
 		for msg := range ws.Send {
-			ws.Conn.WriteMessage(websocket.TextMessage, msg)
+			if err := ws.Conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				slog.Debug("websocket write failed", "remote", r.RemoteAddr, "err", err)
+			}
 		}
 	}()
 	go func() {
@@ -108,11 +252,14 @@ func WsHandler(w http.ResponseWriter, r *http.Request) {
 			ws.Conn.Close()
 			wsMutex.Lock()
 			delete(wsClients, ws)
+			mWsDrops.Inc()
+			mWsConnections.Set(float64(len(wsClients)))
 			wsMutex.Unlock()
 		}()
 		for {
 			_, msg, err := ws.Conn.ReadMessage()
 			if err != nil {
+				slog.Debug("websocket closed", "remote", r.RemoteAddr, "err", err)
 				break
 			}
 			if string(msg) == "ping" {
@@ -122,216 +269,161 @@ func WsHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
-func BroadcastState(state []byte) {
+// BroadcastState pushes the current state to every client, encoding the raw
+// view only when a raw client is connected.
+func BroadcastState() {
 	wsMutex.Lock()
+	var pretty, raw []byte
 	for c := range wsClients {
+		msg := pretty
+		if c.Raw {
+			if raw == nil {
+				raw = GetRawStateBytes()
+			}
+			msg = raw
+		} else if pretty == nil {
+			pretty = GetStateBytes()
+			msg = pretty
+		}
 		select {
-		case c.Send <- state:
+		case c.Send <- msg:
 		default:
+			mWsSlowSkips.Inc()
 		}
 	}
 	wsMutex.Unlock()
 }
 
-func StartFetchers() {
-	go func() {
-		for {
-			FetchTreasury()
-			time.Sleep(250 * time.Millisecond)
-		}
-	}()
-	go func() {
-		for {
-			FetchUsdIdr()
-			time.Sleep(350 * time.Millisecond)
-		}
-	}()
-	go func() {
-		for {
-			time.Sleep(15 * time.Second)
-			wsMutex.Lock()
-			for c := range wsClients {
-				select {
-				case c.Send <- []byte(`{"ping":true}`):
-				default:
-				}
-			}
-			wsMutex.Unlock()
-		}
-	}()
+// sleepCtx waits for d and reports false if ctx was cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
 }
 
-func FetchTreasury() {
-	req, _ := http.NewRequest("POST", "https://api.treasury.id/api/v1/antigrvty/gold/rate", nil)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", "https://treasury.id")
-	req.Header.Set("Referer", "https://treasury.id/")
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var result map[string]interface{}
-	json.Unmarshal(body, &result)
-	data, ok := result["data"].(map[string]interface{})
-	if !ok {
-		return
-	}
-	var buy, sell int
-	switch v := data["buying_rate"].(type) {
-	case string:
-		buy, _ = strconv.Atoi(strings.SplitN(v, ".", 2)[0])
-	case float64:
-		buy = int(v)
-	}
-	switch v := data["selling_rate"].(type) {
-	case string:
-		sell, _ = strconv.Atoi(strings.SplitN(v, ".", 2)[0])
-	case float64:
-		sell = int(v)
-	}
-	upd, _ := data["updated_at"].(string)
-	if buy == 0 || sell == 0 || upd == "" {
-		return
-	}
-	stateMutex.Lock()
-	if shownUpd[upd] {
-		stateMutex.Unlock()
-		return
-	}
-	diff := 0
-	status := "➖"
-	if lastBuy != 0 {
-		diff = buy - lastBuy
-		if buy > lastBuy {
-			status = "🚀"
-		} else if buy < lastBuy {
-			status = "🔻"
-		}
-	}
-	lastBuy = buy
-	shownUpd[upd] = true
-	if len(shownUpd) > 5000 {
-		shownUpd = map[string]bool{upd: true}
-	}
-	buyFmt := formatRupiah(buy)
-	sellFmt := formatRupiah(sell)
-	diffDisplay := formatDiffDisplay(diff, status)
-	h := HistoryItem{
-		BuyingRate:         buy,
-		SellingRate:        sell,
-		Status:             status,
-		Diff:               diff,
-		CreatedAt:          upd,
-		WaktuDisplay:       formatWaktuDisplay(upd, status),
-		DiffDisplay:        diffDisplay,
-		TransactionDisplay: formatTransactionDisplay(buyFmt, sellFmt, diffDisplay),
-		Jt20:               calcProfit(buy, sell, 20000000, 19314000),
-		Jt30:               calcProfit(buy, sell, 30000000, 28980000),
-		Jt40:               calcProfit(buy, sell, 40000000, 38652000),
-		Jt50:               calcProfit(buy, sell, 50000000, 48325000),
-	}
-	state.History = append(state.History, h)
-	if len(state.History) > 1441 {
-		state.History = state.History[len(state.History)-1441:]
+// CloseWebSockets sends a going-away close frame to every client.
+func CloseWebSockets() {
+	wsMutex.Lock()
+	defer wsMutex.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for c := range wsClients {
+		c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server restart"), deadline)
+		c.Conn.Close()
 	}
-	stateMutex.Unlock()
-	BroadcastState(GetStateBytes())
 }
 
-func FetchUsdIdr() {
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, _ := http.NewRequest("GET", "https://www.google.com/finance/quote/USD-IDR", nil)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml")
-	req.AddCookie(&http.Cookie{Name: "CONSENT", Value: "YES+cb.20231208-04-p0.en+FX+410"})
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return
+// StartFetchers runs the polling loops and returns once ctx is cancelled
+// and every loop has exited.
+func StartFetchers(ctx context.Context) {
+	var wg sync.WaitGroup
+	loop := func(f func() error, interval time.Duration) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := newPoller(interval)
+			for ctx.Err() == nil {
+				err := f()
+				if !sleepCtx(ctx, p.next(err, time.Now())) {
+					return
+				}
+			}
+		}()
 	}
-	price := ""
-	doc.Find("div.YMlKec.fxKbKc").Each(func(i int, s *goquery.Selection) {
-		if price == "" {
-			price = strings.TrimSpace(s.Text())
+	loop(func() error { return FetchTreasury(ctx) }, envDuration("TREASURY_POLL_INTERVAL", 250*time.Millisecond))
+	for i, pair := range FxPairs() {
+		interval := envDuration("FX_POLL_INTERVAL", 350*time.Millisecond)
+		if i > 0 {
+			interval = envDuration("FX_SECONDARY_POLL_INTERVAL", 3*time.Second)
 		}
-	})
-	if price == "" {
-		return
+		pair := pair
+		loop(func() error { return FetchFx(ctx, pair) }, interval)
 	}
-	now := time.Now().In(time.FixedZone("WIB", 7*3600)).Format("15:04:05")
-	stateMutex.Lock()
-	if len(state.UsdIdrHistory) == 0 || state.UsdIdrHistory[len(state.UsdIdrHistory)-1].Price != price {
-		state.UsdIdrHistory = append(state.UsdIdrHistory, UsdIdrItem{Price: price, Time: now})
-		if len(state.UsdIdrHistory) > 11 {
-			state.UsdIdrHistory = state.UsdIdrHistory[len(state.UsdIdrHistory)-11:]
-		}
-		stateMutex.Unlock()
-		BroadcastState(GetStateBytes())
-		return
+	if sym := xauRefSymbol(); sym != "" {
+		loop(func() error { return FetchXauRef(ctx, sym) }, envDuration("XAU_REF_POLL_INTERVAL", 10*time.Second))
 	}
-	stateMutex.Unlock()
+	wg.Wait()
 }
 
-func formatRupiah(n int) string {
-	s := strconv.Itoa(n)
-	var out []byte
-	cnt := 0
-	for i := len(s) - 1; i >= 0; i-- {
-		out = append([]byte{s[i]}, out...)
-		cnt++
-		if cnt%3 == 0 && i != 0 {
-			out = append([]byte{'.'}, out...)
+// StartWsPinger keeps idle websocket connections alive until ctx is done.
+func StartWsPinger(ctx context.Context) {
+	for sleepCtx(ctx, 15*time.Second) {
+		wsMutex.Lock()
+		for c := range wsClients {
+			select {
+			case c.Send <- []byte(`{"ping":true}`):
+			default:
+				mWsSlowSkips.Inc()
+			}
 		}
+		wsMutex.Unlock()
 	}
-	return string(out)
 }
 
-func calcProfit(buy, sell, modal, pokok int) string {
-	gram := float64(modal) / float64(buy)
-	val := int(gram*float64(sell)) - pokok
-	gramStr := fmt.Sprintf("%.4f", gram)
-	if val > 0 {
-		return "+" + formatRupiah(val) + "🟢➺" + gramStr + "gr"
-	} else if val < 0 {
-		return "-" + formatRupiah(-val) + "🔴➺" + gramStr + "gr"
+func FetchTreasury(ctx context.Context) error {
+	start := time.Now()
+	resp, err := priceSource.FetchTreasury(ctx)
+	if err != nil {
+		code, reason := failureOf(err)
+		observeFetch("treasury", start, code, reason, err)
+		return err
 	}
-	return formatRupiah(0) + "➖➺" + gramStr + "gr"
-}
-
-func formatDiffDisplay(diff int, status string) string {
-	if status == "🚀" {
-		return "🚀+" + formatRupiah(diff)
-	} else if status == "🔻" {
-		return "🔻-" + formatRupiah(-diff)
+	if resp.Code != http.StatusOK {
+		serr := newStatusError(resp)
+		observeFetch("treasury", start, resp.Code, "status", serr)
+		return serr
 	}
-	return "➖tetap"
-}
-
-func formatTransactionDisplay(buy, sell, diff string) string {
-	return "Harga Beli: " + buy + " Jual: " + sell + " " + diff
-}
-
-func formatWaktuDisplay(t, status string) string {
-	tm, err := time.Parse("2006-01-02 15:04:05", t)
+	rate, reason, err := decodeTreasury(resp.Body)
 	if err != nil {
-		return t + status
+		recordRejected(reason+": "+err.Error(), resp.Body)
+		observeFetch("treasury", start, resp.Code, reason, err)
+		return err
 	}
-	hari := []string{"Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu", "Minggu"}
-	wd := tm.Weekday()
-	idx := int(wd)
-	if idx == 0 {
-		idx = 6
-	} else {
-		idx--
+	buy, sell, upd := rate.BuyingRate.Rupiah(), rate.SellingRate.Rupiah(), rate.UpdatedAt
+	observeFetch("treasury", start, resp.Code, "", nil)
+	mLastBuy.Set(float64(buy))
+	mLastSell.Set(float64(sell))
+	tickAt, _ := time.ParseInLocation(treasuryTimeLayout, upd, wib())
+	stateMutex.Lock()
+	t := Tick{
+		BuyingRate:       buy,
+		SellingRate:      sell,
+		BuyingRateExact:  rate.BuyingRate.String(),
+		SellingRateExact: rate.SellingRate.String(),
+		UpdatedAt:        tickAt,
+	}
+	if prev, ok := lastTick(); ok {
+		if !tickAt.After(prev.UpdatedAt) {
+			stateMutex.Unlock()
+			if tickAt.Before(prev.UpdatedAt) {
+				mOutOfOrder.Inc()
+				slog.Debug("out-of-order tick rejected", "source", "treasury", "updated_at", upd, "last", prev.UpdatedAt)
+			}
+			return nil
+		}
+		t.Diff = buy - prev.BuyingRate
+	}
+	if fx := fxLog[primaryFxPair]; len(fx) > 0 {
+		t.XauUsd = impliedXauUsd(buy, fx[len(fx)-1].Rate)
 	}
-	return fmt.Sprintf("%s %02d:%02d:%02d %s", hari[idx], tm.Hour(), tm.Minute(), tm.Second(), status)
+	appendTick(t)
+	markDirty()
+	updateXau()
+	updateIndicators(t)
+	updateSpread()
+	alerts := checkPositions(t)
+	stateMutex.Unlock()
+	mTicks.Inc()
+	markSourceChange("treasury")
+	slog.Info("new tick", "source", "treasury", "buy", buy, "sell", sell, "diff", t.Diff, "updated_at", upd)
+	mLastTickUnixTs.Set(float64(tickAt.Unix()))
+	BroadcastState()
+	sendPositionAlerts(alerts)
+	return nil
 }
 
 func formatDuration(totalMinutes int) string {
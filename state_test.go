@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRawStateBytesHasNoDisplayStrings(t *testing.T) {
+	InitState()
+	defer InitState()
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, wib())
+	stateMutex.Lock()
+	setTicks(nil)
+	appendTick(Tick{BuyingRate: 1500000, SellingRate: 1450000, UpdatedAt: at})
+	fxLog = map[string][]FxQuote{primaryFxPair: {{Rate: 16234.5, At: at}}}
+	syncFxState(primaryFxPair)
+	state.TreasuryInfo = "<b>Info</b>"
+	state.TransferJam = TransferJam{JamMasuk: "09:30", Durasi: "30 menit", In: at.Add(-30 * time.Minute), At: at}
+	stateMutex.Unlock()
+	defer func() {
+		stateMutex.Lock()
+		setTicks(nil)
+		fxLog = make(map[string][]FxQuote)
+		stateMutex.Unlock()
+	}()
+
+	b := GetRawStateBytes()
+	for _, key := range []string{`"history"`, `"waktu_display"`, `"price"`, `"durasi"`, `"jam_masuk"`, "<b>"} {
+		if strings.Contains(string(b), key) {
+			t.Errorf("raw state contains %s: %s", key, b)
+		}
+	}
+	var raw RawState
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw.Ticks) != 1 || raw.Fx[primaryFxPair][0].Rate != 16234.5 || raw.TreasuryInfo != "Info" {
+		t.Errorf("raw state = %+v", raw)
+	}
+	if raw.Transfer == nil || raw.Transfer.DurationMinutes != 30 {
+		t.Errorf("transfer = %+v", raw.Transfer)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type OHLC struct {
+	Open  int `json:"open"`
+	High  int `json:"high"`
+	Low   int `json:"low"`
+	Close int `json:"close"`
+}
+
+type PriceMove struct {
+	Time time.Time `json:"time"`
+	From int       `json:"from"`
+	To   int       `json:"to"`
+	Diff int       `json:"diff"`
+}
+
+// Stats covers the calendar period [From, To). DataFrom and DataTo are the
+// first and last ticks actually found in it; Partial means the tick store
+// does not reach back to From (retention, or the service was not running).
+type Stats struct {
+	Period        string     `json:"period"`
+	From          time.Time  `json:"from"`
+	To            time.Time  `json:"to"`
+	DataFrom      *time.Time `json:"data_from,omitempty"`
+	DataTo        *time.Time `json:"data_to,omitempty"`
+	Partial       bool       `json:"partial"`
+	Ticks         int        `json:"ticks"`
+	Buy           *OHLC      `json:"buy,omitempty"`
+	Sell          *OHLC      `json:"sell,omitempty"`
+	Changes       int        `json:"changes"`
+	Up            int        `json:"up"`
+	Down          int        `json:"down"`
+	LargestMove   *PriceMove `json:"largest_move,omitempty"`
+	AvgIntervalS  float64    `json:"avg_interval_seconds"`
+	VolatilityPct float64    `json:"volatility_pct"`
+}
+
+// periodRange returns the WIB calendar day, ISO week (from Monday) or month
+// containing at.
+func periodRange(period string, at time.Time) (time.Time, time.Time, bool) {
+	at = at.In(wib())
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, wib())
+	switch period {
+	case "day":
+		return day, day.AddDate(0, 0, 1), true
+	case "week":
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), true
+	case "month":
+		start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, wib())
+		return start, start.AddDate(0, 1, 0), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func updateOHLC(o *OHLC, v int) *OHLC {
+	if o == nil {
+		return &OHLC{Open: v, High: v, Low: v, Close: v}
+	}
+	if v > o.High {
+		o.High = v
+	}
+	if v < o.Low {
+		o.Low = v
+	}
+	o.Close = v
+	return o
+}
+
+// ComputeStats summarises ticks in [from, to) on the buying rate. The tick
+// just before from, if any, is the reference for the first move so a change
+// at the start of the period is counted.
+func ComputeStats(ts []Tick, from, to time.Time) Stats {
+	s := Stats{From: from, To: to, Partial: len(ts) == 0 || ts[0].UpdatedAt.After(from)}
+	var prev *Tick
+	var returns []float64
+	var gaps time.Duration
+	for i := range ts {
+		t := ts[i]
+		if t.UpdatedAt.Before(from) {
+			prev = &ts[i]
+			continue
+		}
+		if !t.UpdatedAt.Before(to) {
+			break
+		}
+		if s.Ticks > 0 {
+			gaps += t.UpdatedAt.Sub(prev.UpdatedAt)
+		} else {
+			at := t.UpdatedAt
+			s.DataFrom = &at
+		}
+		at := t.UpdatedAt
+		s.DataTo = &at
+		s.Ticks++
+		s.Buy = updateOHLC(s.Buy, t.BuyingRate)
+		s.Sell = updateOHLC(s.Sell, t.SellingRate)
+		if prev != nil {
+			diff := t.BuyingRate - prev.BuyingRate
+			if diff != 0 {
+				s.Changes++
+				if diff > 0 {
+					s.Up++
+				} else {
+					s.Down++
+				}
+			}
+			if diff != 0 && (s.LargestMove == nil || absInt(diff) > absInt(s.LargestMove.Diff)) {
+				s.LargestMove = &PriceMove{Time: t.UpdatedAt, From: prev.BuyingRate, To: t.BuyingRate, Diff: diff}
+			}
+			if prev.BuyingRate > 0 && t.BuyingRate > 0 {
+				returns = append(returns, math.Log(float64(t.BuyingRate)/float64(prev.BuyingRate)))
+			}
+		}
+		prev = &ts[i]
+	}
+	if s.Ticks > 1 {
+		s.AvgIntervalS = round2(gaps.Seconds() / float64(s.Ticks-1))
+	}
+	s.VolatilityPct = round4(stddev(returns) * 100)
+	return s
+}
+
+func stddev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	var v float64
+	for _, x := range xs {
+		v += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(v / float64(len(xs)-1))
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// StatsHandler serves GET /api/stats?period=day|week|month&date=YYYY-MM-DD;
+// date defaults to today in WIB.
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	period := strings.ToLower(q.Get("period"))
+	if period == "" {
+		period = "day"
+	}
+	at := time.Now()
+	if d := q.Get("date"); d != "" {
+		t, err := time.ParseInLocation("2006-01-02", d, wib())
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "date harus YYYY-MM-DD")
+			return
+		}
+		at = t
+	}
+	from, to, ok := periodRange(period, at)
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "period harus day, week atau month")
+		return
+	}
+	stateMutex.RLock()
+	s := ComputeStats(ticks, from, to)
+	stateMutex.RUnlock()
+	s.Period = period
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
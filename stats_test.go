@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	from := time.Date(2026, 10, 19, 0, 0, 0, 0, wib())
+	to := from.AddDate(0, 0, 1)
+	tick := func(d time.Duration, buy int) Tick {
+		return Tick{BuyingRate: buy, SellingRate: buy - 10, UpdatedAt: from.Add(d)}
+	}
+	day := []Tick{
+		tick(-time.Hour, 1000),
+		tick(0, 1010),
+		tick(10*time.Minute, 1010),
+		tick(20*time.Minute, 990),
+		tick(30*time.Minute, 1000),
+		tick(24*time.Hour, 2000),
+	}
+	tests := []struct {
+		name                   string
+		ts                     []Tick
+		partial                bool
+		ticks, changes, up, dn int
+		buy                    *OHLC
+		largest                int
+		avgInterval            float64
+	}{
+		// The tick before from makes the move at 00:00 count.
+		{"full day", day, false, 4, 3, 2, 1, &OHLC{1010, 1010, 990, 1000}, -20, 600},
+		{"starts at from", day[1:], false, 4, 2, 1, 1, &OHLC{1010, 1010, 990, 1000}, -20, 600},
+		{"starts inside period", day[2:], true, 3, 2, 1, 1, &OHLC{1010, 1010, 990, 1000}, -20, 600},
+		{"single tick", day[2:3], true, 1, 0, 0, 0, &OHLC{1010, 1010, 1010, 1010}, 0, 0},
+		{"no ticks", nil, true, 0, 0, 0, 0, nil, 0, 0},
+		{"only before and after", []Tick{day[0], day[5]}, false, 0, 0, 0, 0, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ComputeStats(tt.ts, from, to)
+			if s.Partial != tt.partial || s.Ticks != tt.ticks || s.Changes != tt.changes || s.Up != tt.up || s.Down != tt.dn {
+				t.Errorf("partial=%v ticks=%d changes=%d up=%d down=%d, want %v %d %d %d %d",
+					s.Partial, s.Ticks, s.Changes, s.Up, s.Down, tt.partial, tt.ticks, tt.changes, tt.up, tt.dn)
+			}
+			if (s.Buy == nil) != (tt.buy == nil) || (s.Buy != nil && *s.Buy != *tt.buy) {
+				t.Errorf("buy = %+v, want %+v", s.Buy, tt.buy)
+			}
+			largest := 0
+			if s.LargestMove != nil {
+				largest = s.LargestMove.Diff
+			}
+			if largest != tt.largest {
+				t.Errorf("largest move = %d, want %d", largest, tt.largest)
+			}
+			if s.AvgIntervalS != tt.avgInterval {
+				t.Errorf("avg interval = %v, want %v", s.AvgIntervalS, tt.avgInterval)
+			}
+			if (tt.changes > 0) != (s.VolatilityPct > 0) {
+				t.Errorf("volatility = %v with %d changes", s.VolatilityPct, tt.changes)
+			}
+		})
+	}
+}
@@ -1,17 +1,49 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"html"
+	"log/slog"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// tgBot is published by StartTelegramBot and read by fetcher goroutines;
+// nil until the bot is connected.
+var tgBot atomic.Pointer[tgbotapi.BotAPI]
+
+func sendMessage(bot *tgbotapi.BotAPI, msg tgbotapi.MessageConfig) {
+	if _, err := bot.Send(msg); err != nil {
+		slog.Warn("telegram send failed", "source", "telegram", "chat_id", msg.ChatID, "err", err)
+	}
+}
+
+// NotifyAdmin sends an HTML message to ADMIN_CHAT_ID once the bot is running.
+func NotifyAdmin(text string) {
+	adminID, err := strconv.ParseInt(os.Getenv("ADMIN_CHAT_ID"), 10, 64)
+	bot := tgBot.Load()
+	if err != nil || bot == nil {
+		return
+	}
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = "HTML"
+	sendMessage(bot, msg)
+}
+
 func sendLogToAdmin(bot *tgbotapi.BotAPI, user *tgbotapi.User, command, args, status string) {
+	result := "ok"
+	if status != "✅" {
+		result = "denied"
+	}
+	mBotCommands.Inc(command, result)
+	slog.Info("telegram command", "source", "telegram", "command", command, "user_id", user.ID, "username", user.UserName, "args", args, "status", result)
 	adminIDstr := os.Getenv("ADMIN_CHAT_ID")
 	if adminIDstr == "" {
 		return
@@ -38,23 +70,40 @@ func sendLogToAdmin(bot *tgbotapi.BotAPI, user *tgbotapi.User, command, args, st
 	logMsg += fmt.Sprintf("⏰ <b>Waktu:</b> %s WIB", time.Now().In(time.FixedZone("WIB", 7*3600)).Format("2006-01-02 15:04:05"))
 	msg := tgbotapi.NewMessage(adminID, logMsg)
 	msg.ParseMode = "HTML"
-	bot.Send(msg)
+	sendMessage(bot, msg)
 }
 
-func StartTelegramBot() {
+func StartTelegramBot(ctx context.Context) {
 	token := os.Getenv("TELEGRAM_TOKEN")
 	if token == "" {
+		slog.Info("telegram bot disabled: TELEGRAM_TOKEN not set")
 		return
 	}
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
+		slog.Error("telegram bot init failed", "source", "telegram", "err", err)
 		return
 	}
+	tgBot.Store(bot)
+	defer tgBot.Store(nil)
+	slog.Info("telegram bot started", "source", "telegram", "username", bot.Self.UserName)
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := bot.GetUpdatesChan(u)
 	adminID, _ := strconv.ParseInt(os.Getenv("ADMIN_CHAT_ID"), 10, 64)
-	for update := range updates {
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			bot.StopReceivingUpdates()
+			slog.Info("telegram bot stopped", "source", "telegram")
+			return
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			update = u
+		}
 		if update.Message == nil {
 			continue
 		}
@@ -64,8 +113,9 @@ func StartTelegramBot() {
 
 		// Ban check
 		if banned[userID] {
+			slog.Info("telegram message from banned user", "source", "telegram", "user_id", userID)
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Anda telah dibanned. MAMPUSS dahh akkwkwkwkw😂😂😂.")
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -78,10 +128,22 @@ func StartTelegramBot() {
 					"<b>📌 Perintah User:</b>\n" +
 					"━━━━━━━━━━━━━━━━━━━\n" +
 					"⏰ /in &lt;jam&gt; - Input jam transfer (cth: /in 09.30)\n" +
+					"💰 /harga - Harga emas terkini + indikator\n" +
+					"↔️ /spread - Spread beli/jual & titik impas\n" +
+					"💱 /kurs - Kurs USD, SGD, EUR, MYR ke IDR\n" +
+					"🛒 /beli &lt;gram|rupiah&gt; &lt;harga&gt; - Catat pembelian emas\n" +
+					"💸 /jual &lt;id|semua&gt; - Hapus lot yang sudah dijual\n" +
+					"💼 /portfolio - Nilai & untung/rugi portfolio Anda\n" +
+					"🎯 /target &lt;modal&gt; &lt;pokok&gt; &lt;target&gt; [stoploss] - Notifikasi target profit\n" +
+					"📌 /posisi - Lihat posisi & target Anda\n" +
+					"🗑 /hapustarget &lt;id&gt; - Hapus posisi\n" +
 					"ℹ️ /myid - Lihat ID Telegram Anda\n" +
 					"\n<b>👑 Perintah Admin:</b>\n" +
 					"━━━━━━━━━━━━━━━━━━━\n" +
-					"📝 /atur &lt;teks&gt; - Ubah info Treasury\n" +
+					"📝 /atur &lt;teks&gt; - Ubah info Treasury (mendukung **tebal**, *miring*, [teks](url))\n" +
+					"🗓 /jadwal &lt;mulai&gt; &lt;selesai&gt; &lt;teks&gt; - Jadwalkan pengumuman (cth: /jadwal 08:00 17:00 ...)\n" +
+					"📋 /listjadwal - Lihat pengumuman terjadwal\n" +
+					"🗑 /hapusjadwal &lt;id&gt; - Hapus pengumuman\n" +
 					"🔄 /resetjam - Reset data transfer\n" +
 					"🚫 /banid &lt;id&gt; - Ban user by ID\n" +
 					"✅ /unbanid &lt;id&gt; - Unban user by ID\n" +
@@ -91,11 +153,20 @@ func StartTelegramBot() {
 					"<b>Cara gunakan:</b>\n" +
 					"⏰ /in &lt;jam&gt; - Input jam transfer\n" +
 					"   Contoh: /in 09.30\n" +
+					"💰 /harga - Harga emas terkini + indikator\n" +
+					"↔️ /spread - Spread beli/jual & titik impas\n" +
+					"💱 /kurs - Kurs USD, SGD, EUR, MYR ke IDR\n" +
+					"🛒 /beli &lt;gram|rupiah&gt; &lt;harga&gt; - Catat pembelian emas\n" +
+					"💸 /jual &lt;id|semua&gt; - Hapus lot yang sudah dijual\n" +
+					"💼 /portfolio - Nilai & untung/rugi portfolio Anda\n" +
+					"🎯 /target &lt;modal&gt; &lt;pokok&gt; &lt;target&gt; [stoploss] - Notifikasi target profit\n" +
+					"📌 /posisi - Lihat posisi & target Anda\n" +
+					"🗑 /hapustarget &lt;id&gt; - Hapus posisi\n" +
 					"ℹ️ /myid - Lihat ID Telegram Anda\n"
 			}
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, helpText)
 			msg.ParseMode = "HTML"
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -108,7 +179,204 @@ func StartTelegramBot() {
 			)
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, userInfo)
 			msg.ParseMode = "HTML"
-			bot.Send(msg)
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /harga
+		if strings.HasPrefix(text, "/harga") {
+			sendLogToAdmin(bot, user, "harga", "", "✅")
+			stateMutex.RLock()
+			t, ok := lastTick()
+			snap := state.Indicators
+			stateMutex.RUnlock()
+			if !ok {
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "⏳ Belum ada data harga dari Treasury."))
+				continue
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, formatHarga(t, snap))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /spread
+		if strings.HasPrefix(text, "/spread") {
+			sendLogToAdmin(bot, user, "spread", "", "✅")
+			stateMutex.RLock()
+			s := state.Spread
+			stateMutex.RUnlock()
+			if s.Latest == nil {
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "⏳ Belum ada data harga dari Treasury."))
+				continue
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, formatSpread(s))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /beli <gram|rupiah> <harga>
+		if strings.HasPrefix(text, "/beli") {
+			args := strings.Fields(strings.TrimPrefix(text, "/beli"))
+			if len(args) == 0 || len(args) > 2 {
+				sendLogToAdmin(bot, user, "beli", strings.Join(args, " "), "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /beli <gram|rupiah> <harga>\nContoh: /beli 2.5gr 1,45jt atau /beli 1jt\nTanpa harga, dipakai harga beli terkini."))
+				continue
+			}
+			harga := ""
+			if len(args) == 2 {
+				harga = args[1]
+			}
+			lot, err := AddLot(userID, args[0], harga, time.Now())
+			if err != nil {
+				sendLogToAdmin(bot, user, "beli", strings.Join(args, " "), "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ "+err.Error()))
+				continue
+			}
+			sendLogToAdmin(bot, user, "beli", strings.Join(args, " "), "✅")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "✅ <b>Pembelian dicatat</b>\n"+formatLot(lot, 0))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /jual <id|semua>
+		if strings.HasPrefix(text, "/jual") {
+			arg := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(text, "/jual")))
+			id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+			if arg == "semua" {
+				id, err = 0, nil
+			}
+			if err != nil || id < 0 || (id == 0 && arg != "semua") {
+				sendLogToAdmin(bot, user, "jual", arg, "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /jual <id|semua>\nLihat id lot dengan /portfolio"))
+				continue
+			}
+			_, t, ok := userLots(userID)
+			removed := RemoveLots(userID, id)
+			if len(removed) == 0 {
+				sendLogToAdmin(bot, user, "jual", arg, "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Lot tidak ditemukan. Lihat /portfolio"))
+				continue
+			}
+			sendLogToAdmin(bot, user, "jual", arg, "✅")
+			sell := 0
+			if ok {
+				sell = t.SellingRate
+			}
+			reply := "💸 <b>Lot dijual</b>\n"
+			for _, l := range removed {
+				reply += formatLot(l, sell) + "\n"
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, reply)
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /portfolio
+		if strings.HasPrefix(text, "/portfolio") {
+			sendLogToAdmin(bot, user, "portfolio", "", "✅")
+			lots, t, ok := userLots(userID)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, formatPortfolio(lots, t, ok))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /target <modal> <pokok> <target> [stoploss]
+		if strings.HasPrefix(text, "/target") {
+			args := strings.Fields(strings.TrimPrefix(text, "/target"))
+			var vals []int
+			for _, a := range args {
+				if v, ok := parseRupiah(a); ok {
+					vals = append(vals, v)
+				}
+			}
+			if len(args) < 3 || len(args) > 4 || len(vals) != len(args) {
+				sendLogToAdmin(bot, user, "target", strings.Join(args, " "), "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /target <modal> <pokok> <target> [stoploss]\nContoh: /target 20jt 19.314.000 500rb 300rb"))
+				continue
+			}
+			if len(vals) == 3 {
+				vals = append(vals, 0)
+			}
+			p, err := AddPosition(userID, update.Message.Chat.ID, vals[0], vals[1], vals[2], vals[3], time.Now())
+			if err != nil {
+				sendLogToAdmin(bot, user, "target", strings.Join(args, " "), "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ "+err.Error()))
+				continue
+			}
+			sendLogToAdmin(bot, user, "target", strings.Join(args, " "), "✅")
+			_, t, ok := userPositions(userID)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "✅ <b>Posisi dipantau</b>\n"+formatPosition(p, t, ok)+"\n\nAnda akan diberi tahu saat target atau stop-loss pertama kali tersentuh.")
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /posisi
+		if strings.HasPrefix(text, "/posisi") {
+			sendLogToAdmin(bot, user, "posisi", "", "✅")
+			ps, t, ok := userPositions(userID)
+			reply := "📭 Belum ada posisi. Daftarkan dengan /target &lt;modal&gt; &lt;pokok&gt; &lt;target&gt; [stoploss]"
+			if len(ps) > 0 {
+				reply = "📌 <b>Posisi Anda</b>\n━━━━━━━━━━━━━━━━━━━"
+				for _, p := range ps {
+					reply += "\n" + formatPosition(p, t, ok)
+				}
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, reply)
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /hapustarget <id>
+		if strings.HasPrefix(text, "/hapustarget") {
+			arg := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(text, "/hapustarget")), "#")
+			id, err := strconv.Atoi(arg)
+			if err != nil || !RemovePosition(userID, id) {
+				sendLogToAdmin(bot, user, "hapustarget", arg, "🚫")
+				sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Posisi tidak ditemukan. Lihat /posisi"))
+				continue
+			}
+			sendLogToAdmin(bot, user, "hapustarget", arg, "✅")
+			sendMessage(bot, tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("🗑 Posisi #%d dihapus.", id)))
+			continue
+		}
+
+		// /kurs
+		if strings.HasPrefix(text, "/kurs") {
+			sendLogToAdmin(bot, user, "kurs", "", "✅")
+			var lines []string
+			for _, pair := range FxPairs() {
+				it, ok := LatestFx(pair)
+				if !ok {
+					lines = append(lines, fmt.Sprintf("• <b>%s</b>: belum ada data", strings.Replace(pair, "-", "/", 1)))
+					continue
+				}
+				icon := "➖"
+				if it.Change > 0 {
+					icon = "🟢"
+				} else if it.Change < 0 {
+					icon = "🔴"
+				}
+				lines = append(lines, fmt.Sprintf("• <b>%s</b>: %s %s %+.2f%% <i>(%s)</i>", strings.Replace(pair, "-", "/", 1), it.Price, icon, it.ChangePct, it.Time))
+			}
+			stateMutex.RLock()
+			xau := state.Xau
+			stateMutex.RUnlock()
+			if xau.Implied > 0 {
+				lines = append(lines, fmt.Sprintf("\n🥇 <b>Implied XAU/USD:</b> $%.2f/oz", xau.Implied))
+				if xau.Reference > 0 {
+					lines = append(lines, fmt.Sprintf("🌐 <b>Spot %s:</b> $%.2f (%+.2f%%)", xau.Source, xau.Reference, xau.PremiumPct))
+				}
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "💱 <b>Kurs Google Finance</b>\n━━━━━━━━━━━━━━━━━━━\n"+strings.Join(lines, "\n"))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -120,20 +388,20 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "in", jam, "✅")
 			if jam == "" {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /in <jam>\nContoh: /in 09.30")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			parts := strings.Split(jam, ":")
 			if len(parts) != 2 {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Format jam tidak valid!\nContoh: /in 09.30")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			hour, err1 := strconv.Atoi(parts[0])
 			minute, err2 := strconv.Atoi(parts[1])
 			if err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Format jam tidak valid!\nContoh: /in 09.30")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			now := time.Now().In(time.FixedZone("WIB", 7*3600))
@@ -141,7 +409,7 @@ func StartTelegramBot() {
 			currentMinutes := now.Hour()*60 + now.Minute()
 			if inputMinutes > currentMinutes {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Perhatikan Jam saat ini guys!\n")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			stateMutex.Lock()
@@ -155,7 +423,7 @@ func StartTelegramBot() {
 					existingMinutes := exHour*60 + exMinute
 					if inputMinutes <= existingMinutes {
 						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "✅ Terimakasih telah berpartisipasi, ingfo ini sangat bermanfaat bagi orang lain 🙏🏻")
-						bot.Send(msg)
+						sendMessage(bot, msg)
 						continue
 					}
 				}
@@ -169,11 +437,13 @@ func StartTelegramBot() {
 				JamMasuk:   jam,
 				Durasi:     formatDuration(durationMinutes),
 				LastUpdate: now.Format("15:04"),
+				In:         time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location()),
+				At:         now.Truncate(time.Minute),
 			}
 			stateMutex.Unlock()
-			BroadcastState(GetStateBytes())
+			BroadcastState()
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ Jam transfer: %s\nTerimakasih telah berpartisipasi, ingfo ini sangat bermanfaat bagi orang lain 🙏🏻", jam))
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -187,20 +457,110 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "atur", isi, status)
 			if int64(userID) != adminID {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if isi == "" {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /atur <kalimat>")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
-			stateMutex.Lock()
-			state.TreasuryInfo = strings.ReplaceAll(strings.ReplaceAll(isi, "  ", "&nbsp;&nbsp;"), "\n", "<br>")
-			stateMutex.Unlock()
-			BroadcastState(GetStateBytes())
+			skip := utf16Len(text[:len(text)-len(strings.TrimLeft(strings.TrimPrefix(text, "/atur"), " \t\n"))])
+			SetTreasuryBase(RenderTreasuryInfo(text, update.Message.Entities, skip))
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "✅ Info Treasury berhasil diubah!")
-			bot.Send(msg)
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /jadwal <mulai> <selesai> <teks>
+		if strings.HasPrefix(text, "/jadwal") {
+			args := strings.TrimPrefix(text, "/jadwal")
+			status := "🚫"
+			if int64(userID) == adminID {
+				status = "✅"
+			}
+			sendLogToAdmin(bot, user, "jadwal", strings.TrimSpace(args), status)
+			if int64(userID) != adminID {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
+				sendMessage(bot, msg)
+				continue
+			}
+			start, end, body, err := ParseJadwal(args, time.Now().In(wib()))
+			if err != nil || strings.TrimSpace(body) == "" {
+				reason := "teks pengumuman kosong"
+				if err != nil {
+					reason = err.Error()
+				}
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ "+reason+"\nGunakan: /jadwal <mulai> <selesai> <teks>\nContoh: /jadwal 08:00 17:00 Transfer sedang gangguan\nAtau: /jadwal 2026-01-05T08:00 2026-01-05T17:00 <teks>")
+				sendMessage(bot, msg)
+				continue
+			}
+			info := RenderTreasuryInfo(text, update.Message.Entities, utf16Len(text[:len(text)-len(body)]))
+			a := AddAnnouncement(start, end, info, strings.TrimSpace(body))
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ Pengumuman #%d dijadwalkan\n🕗 %s s/d %s WIB", a.ID, a.Start.In(wib()).Format("02/01 15:04"), a.End.In(wib()).Format("02/01 15:04")))
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /listjadwal
+		if strings.HasPrefix(text, "/listjadwal") {
+			status := "🚫"
+			if int64(userID) == adminID {
+				status = "✅"
+			}
+			sendLogToAdmin(bot, user, "listjadwal", "", status)
+			if int64(userID) != adminID {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
+				sendMessage(bot, msg)
+				continue
+			}
+			list := ListAnnouncements()
+			if len(list) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "📋 Tidak ada pengumuman terjadwal.")
+				sendMessage(bot, msg)
+				continue
+			}
+			var lines []string
+			now := time.Now()
+			for _, a := range list {
+				mark := "⏳"
+				if !a.Start.After(now) {
+					mark = "📢"
+				}
+				lines = append(lines, fmt.Sprintf("%s <b>#%d</b> %s s/d %s\n%s", mark, a.ID, a.Start.In(wib()).Format("02/01 15:04"), a.End.In(wib()).Format("02/01 15:04"), html.EscapeString(a.Source)))
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "📋 <b>Pengumuman Terjadwal</b>\n━━━━━━━━━━━━━━━━━━━\n"+strings.Join(lines, "\n\n"))
+			msg.ParseMode = "HTML"
+			sendMessage(bot, msg)
+			continue
+		}
+
+		// /hapusjadwal <id>
+		if strings.HasPrefix(text, "/hapusjadwal") {
+			idstr := strings.TrimSpace(strings.TrimPrefix(text, "/hapusjadwal"))
+			status := "🚫"
+			if int64(userID) == adminID {
+				status = "✅"
+			}
+			sendLogToAdmin(bot, user, "hapusjadwal", idstr, status)
+			if int64(userID) != adminID {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
+				sendMessage(bot, msg)
+				continue
+			}
+			id, err := strconv.Atoi(strings.TrimPrefix(idstr, "#"))
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /hapusjadwal <id>\nContoh: /hapusjadwal 3")
+				sendMessage(bot, msg)
+				continue
+			}
+			if !RemoveAnnouncement(id) {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("ℹ️ Pengumuman #%d tidak ditemukan.", id))
+				sendMessage(bot, msg)
+				continue
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ Pengumuman #%d dihapus.", id))
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -213,15 +573,15 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "resetjam", "", status)
 			if int64(userID) != adminID {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			stateMutex.Lock()
 			state.TransferJam = TransferJam{}
 			stateMutex.Unlock()
-			BroadcastState(GetStateBytes())
+			BroadcastState()
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "✅ Data transfer telah direset")
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -235,35 +595,35 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "banid", idstr, status)
 			if int64(userID) != adminID {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if idstr == "" {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /banid <user_id>\nContoh: /banid 123456789")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			targetID, err := strconv.ParseInt(idstr, 10, 64)
 			if err != nil {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ ID harus berupa angka!")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if targetID == int64(userID) {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Anda tidak bisa ban diri sendiri!")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if banned[targetID] {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("ℹ️ User ID <code>%d</code> sudah dalam daftar banned.", targetID))
 				msg.ParseMode = "HTML"
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			banned[targetID] = true
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ <b>User Dibanned</b>\n━━━━━━━━━━━━━━━\n🆔 User ID: <code>%d</code>\n📊 Total banned: %d user", targetID, len(banned)))
 			msg.ParseMode = "HTML"
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -277,30 +637,30 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "unbanid", idstr, status)
 			if int64(userID) != adminID {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if idstr == "" {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Gunakan: /unbanid <user_id>\nContoh: /unbanid 123456789")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			targetID, err := strconv.ParseInt(idstr, 10, 64)
 			if err != nil {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ ID harus berupa angka!")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if !banned[targetID] {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("ℹ️ User ID <code>%d</code> tidak ada dalam daftar banned.", targetID))
 				msg.ParseMode = "HTML"
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			delete(banned, targetID)
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ <b>User Diunban</b>\n━━━━━━━━━━━━━━━\n🆔 User ID: <code>%d</code>\n📊 Total banned: %d user", targetID, len(banned)))
 			msg.ParseMode = "HTML"
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 
@@ -313,12 +673,12 @@ func StartTelegramBot() {
 			sendLogToAdmin(bot, user, "listban", "", status)
 			if int64(userID) != adminID {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "⛔ Perintah ini hanya untuk Admin.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			if len(banned) == 0 {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "📋 Tidak ada user yang dibanned.")
-				bot.Send(msg)
+				sendMessage(bot, msg)
 				continue
 			}
 			var ids []string
@@ -328,7 +688,7 @@ func StartTelegramBot() {
 			sort.Strings(ids)
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("📋 <b>Daftar User Banned</b>\n━━━━━━━━━━━━━━━━━━━\n%s\n\n📊 Total: %d user", strings.Join(ids, "\n"), len(banned)))
 			msg.ParseMode = "HTML"
-			bot.Send(msg)
+			sendMessage(bot, msg)
 			continue
 		}
 	}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const treasuryTimeLayout = "2006-01-02 15:04:05"
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Decimal keeps the exact text of a Treasury rate, which the API sends
+// either as a JSON string ("1234567.89") or as a bare number.
+type Decimal struct {
+	text string
+}
+
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		d.text = ""
+		return nil
+	}
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+	}
+	if !decimalPattern.MatchString(s) {
+		return fmt.Errorf("not a decimal: %q", s)
+	}
+	d.text = s
+	return nil
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.text)
+}
+
+func (d Decimal) String() string {
+	return d.text
+}
+
+func (d Decimal) IsZero() bool {
+	return d.text == "" || d.Float() == 0
+}
+
+func (d Decimal) Float() float64 {
+	f, _ := strconv.ParseFloat(d.text, 64)
+	return f
+}
+
+// Rupiah rounds to whole rupiah for display and profit math.
+func (d Decimal) Rupiah() int {
+	return int(math.Round(d.Float()))
+}
+
+type treasuryResponse struct {
+	Data *treasuryRate `json:"data"`
+}
+
+type treasuryRate struct {
+	BuyingRate  Decimal `json:"buying_rate"`
+	SellingRate Decimal `json:"selling_rate"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+type RejectedPayload struct {
+	At     string `json:"at"`
+	Reason string `json:"reason"`
+	Body   string `json:"body"`
+}
+
+var (
+	rejectedMutex    sync.Mutex
+	rejectedPayloads []RejectedPayload
+)
+
+// decodeTreasury parses and validates one API response. The returned error
+// tells the caller whether it was malformed (decode) or implausible.
+func decodeTreasury(body []byte) (treasuryRate, string, error) {
+	var resp treasuryResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return treasuryRate{}, "decode", err
+	}
+	if resp.Data == nil {
+		return treasuryRate{}, "decode", errors.New("missing data object")
+	}
+	r := *resp.Data
+	if err := validateTreasury(r); err != nil {
+		return r, "invalid", err
+	}
+	return r, "", nil
+}
+
+// treasuryRateBounds is the plausible per-gram price range in rupiah.
+func treasuryRateBounds() (lo, hi float64) {
+	return float64(envInt("TREASURY_MIN_RATE", 200000)), float64(envInt("TREASURY_MAX_RATE", 20000000))
+}
+
+func validateTreasury(r treasuryRate) error {
+	if r.BuyingRate.IsZero() || r.SellingRate.IsZero() || r.UpdatedAt == "" {
+		return fmt.Errorf("incomplete rate: buy=%q sell=%q updated_at=%q", r.BuyingRate, r.SellingRate, r.UpdatedAt)
+	}
+	if _, err := time.ParseInLocation(treasuryTimeLayout, r.UpdatedAt, wib()); err != nil {
+		return fmt.Errorf("bad updated_at %q", r.UpdatedAt)
+	}
+	lo, hi := treasuryRateBounds()
+	buy, sell := r.BuyingRate.Float(), r.SellingRate.Float()
+	if buy < lo || buy > hi || sell < lo || sell > hi {
+		return fmt.Errorf("rate out of range [%.0f, %.0f]: buy=%s sell=%s", lo, hi, r.BuyingRate, r.SellingRate)
+	}
+	if sell > buy {
+		return fmt.Errorf("selling rate %s above buying rate %s", r.SellingRate, r.BuyingRate)
+	}
+	return nil
+}
+
+func recordRejected(reason string, body []byte) {
+	if len(body) > 4096 {
+		body = body[:4096]
+	}
+	rejectedMutex.Lock()
+	rejectedPayloads = append(rejectedPayloads, RejectedPayload{
+		At:     time.Now().In(wib()).Format(time.RFC3339),
+		Reason: reason,
+		Body:   string(body),
+	})
+	if len(rejectedPayloads) > 50 {
+		rejectedPayloads = rejectedPayloads[len(rejectedPayloads)-50:]
+	}
+	rejectedMutex.Unlock()
+}
+
+func RejectedHandler(w http.ResponseWriter, r *http.Request) {
+	rejectedMutex.Lock()
+	b, _ := json.Marshal(rejectedPayloads)
+	rejectedMutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		rupiah  int
+		wantErr bool
+	}{
+		{`"1234567.89"`, "1234567.89", 1234568, false},
+		{`1234567`, "1234567", 1234567, false},
+		{`" 12.5 "`, "12.5", 13, false},
+		{`"-3.25"`, "-3.25", -3, false},
+		{`null`, "", 0, false},
+		{`"1e6"`, "", 0, true},
+		{`"1,5"`, "", 0, true},
+		{`""`, "", 0, true},
+		{`true`, "", 0, true},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (d.String() != tt.want || d.Rupiah() != tt.rupiah) {
+			t.Errorf("Unmarshal(%s) = %q (Rp%d), want %q (Rp%d)", tt.in, d, d.Rupiah(), tt.want, tt.rupiah)
+		}
+	}
+	if b, _ := json.Marshal(Decimal{text: "12.50"}); string(b) != `"12.50"` {
+		t.Errorf("Marshal kept %s, want \"12.50\"", b)
+	}
+	for _, s := range []string{"", "0", "0.00"} {
+		if !(Decimal{text: s}).IsZero() {
+			t.Errorf("Decimal(%q).IsZero() = false", s)
+		}
+	}
+}
+
+func TestDecodeTreasury(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		reason string
+		buy    string
+	}{
+		{"string rates", `{"data":{"buying_rate":"1234567.89","selling_rate":"1200000","updated_at":"2026-10-19 10:00:00"}}`, "", "1234567.89"},
+		{"number rates", `{"data":{"buying_rate":1234567,"selling_rate":1200000,"updated_at":"2026-10-19 10:00:00"}}`, "", "1234567"},
+		{"not json", `<html>`, "decode", ""},
+		{"no data", `{"message":"ok"}`, "decode", ""},
+		{"bad decimal", `{"data":{"buying_rate":"1.234.567","selling_rate":"1200000","updated_at":"2026-10-19 10:00:00"}}`, "decode", ""},
+		{"null rate", `{"data":{"buying_rate":null,"selling_rate":"1200000","updated_at":"2026-10-19 10:00:00"}}`, "invalid", ""},
+		{"zero rate", `{"data":{"buying_rate":"0","selling_rate":"1200000","updated_at":"2026-10-19 10:00:00"}}`, "invalid", "0"},
+		{"no updated_at", `{"data":{"buying_rate":"1234567","selling_rate":"1200000"}}`, "invalid", "1234567"},
+		{"bad updated_at", `{"data":{"buying_rate":"1234567","selling_rate":"1200000","updated_at":"19/10/2026"}}`, "invalid", "1234567"},
+		{"below min", `{"data":{"buying_rate":"1234","selling_rate":"1200000","updated_at":"2026-10-19 10:00:00"}}`, "invalid", "1234"},
+		{"above max", `{"data":{"buying_rate":"1234567","selling_rate":"99000000","updated_at":"2026-10-19 10:00:00"}}`, "invalid", "1234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, reason, err := decodeTreasury([]byte(tt.body))
+			if reason != tt.reason || (err == nil) != (tt.reason == "") {
+				t.Fatalf("reason = %q, err = %v, want reason %q", reason, err, tt.reason)
+			}
+			if r.BuyingRate.String() != tt.buy {
+				t.Errorf("buying_rate = %q, want %q", r.BuyingRate, tt.buy)
+			}
+		})
+	}
+}
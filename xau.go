@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const gramsPerTroyOunce = 31.1034768
+
+type XauInfo struct {
+	Implied    float64   `json:"implied"`
+	Reference  float64   `json:"reference"`
+	PremiumPct float64   `json:"premium_pct"`
+	UsdIdr     float64   `json:"usd_idr"`
+	BuyingRate int       `json:"buying_rate"`
+	Source     string    `json:"source"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+var xauRef float64
+
+// xauRefSymbol is the Google Finance quote used as global spot reference.
+// XAU_REF_QUOTE=off disables the feed.
+func xauRefSymbol() string {
+	sym := strings.TrimSpace(os.Getenv("XAU_REF_QUOTE"))
+	if sym == "" {
+		return "XAU-USD"
+	}
+	if strings.EqualFold(sym, "off") {
+		return ""
+	}
+	return strings.ToUpper(sym)
+}
+
+// impliedXauUsd converts an IDR per gram price into USD per troy ounce.
+func impliedXauUsd(idrPerGram int, usdIdr float64) float64 {
+	if idrPerGram <= 0 || usdIdr <= 0 {
+		return 0
+	}
+	return math.Round(float64(idrPerGram)/usdIdr*gramsPerTroyOunce*100) / 100
+}
+
+// updateXau must be called with stateMutex held.
+func updateXau() {
+	last, ok := lastTick()
+	fx := fxLog[primaryFxPair]
+	if !ok || len(fx) == 0 {
+		return
+	}
+	buy := last.BuyingRate
+	usd := fx[len(fx)-1].Rate
+	x := XauInfo{
+		Implied:    impliedXauUsd(buy, usd),
+		Reference:  xauRef,
+		UsdIdr:     usd,
+		BuyingRate: buy,
+		Source:     xauRefSymbol(),
+		UpdatedAt:  time.Now().In(wib()).Truncate(time.Second),
+	}
+	if xauRef > 0 {
+		x.PremiumPct = math.Round((x.Implied-xauRef)/xauRef*10000) / 100
+	}
+	state.Xau = x
+}
+
+func FetchXauRef(ctx context.Context, symbol string) error {
+	rate, _, err := fetchQuote(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	stateMutex.Lock()
+	if rate == xauRef {
+		stateMutex.Unlock()
+		return nil
+	}
+	xauRef = rate
+	updateXau()
+	stateMutex.Unlock()
+	BroadcastState()
+	return nil
+}